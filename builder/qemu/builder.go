@@ -14,6 +14,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 const BuilderId = "transcend.qemu"
@@ -27,13 +28,39 @@ var _ packersdk.Builder = &Builder{}
 
 func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
 
+// generatedDataNames are the build variables set by the builder, in addition
+// to the port_forward, guest_forward and shared_folder ones.
+var generatedDataNames = []string{
+	"DiskPaths",
+	"GuestAddress",
+	"HTTPIP",
+	"QMPSocket",
+	"SPICEAddress",
+	"SPICEPassword",
+	"SSHHostPort",
+	"VNCPassword",
+	"VNCPort",
+	"VNCSocket",
+}
+
 func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 	warnings, errs := b.config.Prepare(raws...)
 	if errs != nil {
 		return nil, warnings, errs
 	}
 
-	return nil, warnings, nil
+	generatedData := append([]string{}, generatedDataNames...)
+	for _, pf := range b.config.PortForwards {
+		generatedData = append(generatedData, pf.generatedDataName())
+	}
+	for _, gf := range b.config.GuestForwards {
+		generatedData = append(generatedData, gf.generatedDataName())
+	}
+	for _, sf := range b.config.SharedFolders {
+		generatedData = append(generatedData, sf.generatedDataName())
+	}
+
+	return generatedData, warnings, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
@@ -60,6 +87,24 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		})
 	}
 
+	if b.config.KernelPath != "" {
+		steps = append(steps, &commonsteps.StepDownload{
+			Checksum:    b.config.KernelChecksum,
+			Description: "kernel",
+			ResultKey:   "kernel_path",
+			Url:         []string{b.config.KernelPath},
+		})
+	}
+
+	if b.config.InitrdPath != "" {
+		steps = append(steps, &commonsteps.StepDownload{
+			Checksum:    b.config.InitrdChecksum,
+			Description: "initrd",
+			ResultKey:   "initrd_path",
+			Url:         []string{b.config.InitrdPath},
+		})
+	}
+
 	steps = append(steps, new(stepPrepareOutputDir),
 		&commonsteps.StepCreateFloppy{
 			Files:       b.config.FloppyConfig.FloppyFiles,
@@ -71,6 +116,31 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			Content: b.config.CDConfig.CDContent,
 			Label:   b.config.CDConfig.CDLabel,
 		},
+	)
+
+	generateSSHKey := b.config.CloudInit != nil && b.config.CloudInit.UserData == ""
+	if b.config.Ignition != nil && b.config.CommConfig.Comm.Type == "ssh" {
+		generateSSHKey = true
+	}
+	if generateSSHKey {
+		steps = append(steps, &communicator.StepSSHKeyGen{
+			CommConf:            &b.config.CommConfig.Comm,
+			SSHTemporaryKeyPair: b.config.CommConfig.Comm.SSH.SSHTemporaryKeyPair,
+		})
+	}
+
+	commInterface, commNetdevID := b.config.commNetworkInterface()
+
+	steps = append(steps,
+		&stepCreateCloudInitSeed{
+			CloudInit: b.config.CloudInit,
+			Comm:      &b.config.CommConfig.Comm,
+			VMName:    b.config.VMName,
+		},
+		&stepPrepareIgnition{
+			Ignition: b.config.Ignition,
+			Comm:     &b.config.CommConfig.Comm,
+		},
 		&stepCreateDisk{
 			AdditionalDiskSize: b.config.AdditionalDiskSize,
 			DiskImage:          b.config.DiskImage,
@@ -98,13 +168,30 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 			DiskSize:        b.config.DiskSize,
 			QemuImgArgs:     b.config.QemuImgArgs,
 		},
+		&stepPrepareEFIVars{
+			EFIFirmwareVars: b.config.EFIFirmwareVars,
+			SecureBootKeys:  b.config.SecureBootKeys,
+			OutputDir:       b.config.OutputDir,
+		},
 		new(stepHTTPIPDiscover),
 		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&stepPortForward{
 			CommunicatorType: b.config.CommConfig.Comm.Type,
-			NetBridge:        b.config.NetBridge,
+			NetworkInterface: commInterface,
+			PortForwards:     b.config.PortForwards,
+			NetIPv6:          b.config.NetIPv6,
 		},
 		new(stepConfigureVNC),
+		new(stepConfigureSPICE),
+		&stepStartPasst{
+			CommunicatorType:  b.config.CommConfig.Comm.Type,
+			CommunicatorPort:  b.config.CommConfig.Comm.Port(),
+			CommNetdevID:      commNetdevID,
+			NetworkInterfaces: b.config.networkInterfaces(),
+		},
+		&stepStartVirtiofsd{
+			SharedFolders: b.config.SharedFolders,
+		},
 		&stepRun{
 			DiskImage: b.config.DiskImage,
 		},
@@ -114,7 +201,12 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		&stepTypeBootCommand{},
 		&stepWaitGuestAddress{
 			CommunicatorType: b.config.CommConfig.Comm.Type,
-			NetBridge:        b.config.NetBridge,
+			NetworkInterface: commInterface,
+			NetdevID:         commNetdevID,
+			Sources:          b.config.GuestAddressSources,
+			DnsmasqLeaseFile: b.config.DnsmasqLeaseFile,
+			GuestAddress:     b.config.GuestAddress,
+			PollInterval:     b.config.GuestAddressPollInterval,
 			timeout:          b.config.CommConfig.Comm.SSHTimeout,
 		},
 		&communicator.StepConnect{
@@ -151,6 +243,14 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	for _, gf := range b.config.GuestForwards {
+		generatedData.Put(gf.generatedDataName(), gf.GuestAddress)
+	}
+	for _, sf := range b.config.SharedFolders {
+		generatedData.Put(sf.generatedDataName(), sf.Tag)
+	}
+
 	// Run
 	b.runner = commonsteps.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
 	b.runner.Run(ctx, state)
@@ -204,6 +304,13 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	artifact.state["diskSize"] = b.config.DiskSize
 	artifact.state["domainType"] = b.config.Accelerator
 
+	var macAddresses []string
+	for _, iface := range b.config.networkInterfaces() {
+		macAddresses = append(macAddresses, iface.MACAddress)
+	}
+	artifact.state["macAddress"] = commInterface.MACAddress
+	artifact.state["macAddresses"] = macAddresses
+
 	return artifact, nil
 }
 
@@ -0,0 +1,56 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"errors"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+)
+
+// The `cloud_init` block makes Packer generate a cloud-init
+// [NoCloud](https://cloudinit.readthedocs.io/en/latest/topics/datasources/nocloud.html)
+// seed and attach it to the VM as a CD labeled `cidata`. This is mostly useful
+// with `disk_image` set to `true`, to configure a user in a cloud image. With
+// `disk_image`, a `cloud_init` block with the default settings is implied when
+// no SSH credentials are configured.
+//
+// The seed is created in a temporary directory with the same tooling as
+// `cd_files`, so one of `xorriso`, `mkisofs`, `hdiutil` or `oscdimg` must be
+// available on the build machine, or the build fails, also with the implied
+// seed. The seed is never part of the artifact.
+//
+// In HCL2:
+// ```hcl
+//   cloud_init {
+//     network_config = file("network-config.yml")
+//   }
+// ```
+type CloudInitConfig struct {
+	// The content of the `user-data` file of the seed. The content is used
+	// as is, Packer template engine is not run on it. When unset, Packer
+	// generates a `#cloud-config` which creates the `ssh_username` user with
+	// passwordless sudo, authorizing a temporary SSH key generated for the
+	// build and, if set, the `ssh_password`.
+	UserData string `mapstructure:"user_data" required:"false"`
+	// The content of the `meta-data` file of the seed. Defaults to an
+	// `instance-id` and a `local-hostname` set to `vm_name`.
+	MetaData string `mapstructure:"meta_data" required:"false"`
+	// The content of the `network-config` file of the seed. When unset, no
+	// `network-config` file is written and cloud-init uses its default
+	// network configuration.
+	NetworkConfig string `mapstructure:"network_config" required:"false"`
+
+	// Whether the block is implied by a disk_image without SSH credentials
+	// rather than configured.
+	implied bool
+}
+
+func (c *CloudInitConfig) Prepare(comm *communicator.Config) []error {
+	var errs []error
+
+	if c.UserData == "" && comm.Type != "ssh" {
+		errs = append(errs, errors.New("cloud_init.user_data must be set when the communicator is not ssh"))
+	}
+
+	return errs
+}
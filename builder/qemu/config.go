@@ -1,17 +1,21 @@
 //go:generate packer-sdc struct-markdown
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,QemuImgArgs
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,QemuImgArgs,CloudInitConfig,FwCfgConfig,SMBIOSConfig,IgnitionConfig,NetworkInterfaceConfig,PortForwardConfig,GuestForwardConfig,NUMANodeConfig,SecureBootKeysConfig,SharedFolderConfig
 
 package qemu
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
@@ -32,6 +36,18 @@ var accels = map[string]struct{}{
 	"whpx": {},
 }
 
+var cpuFeatureRe = regexp.MustCompile(`^([+-][A-Za-z0-9_.-]+|[A-Za-z0-9_.-]+=[A-Za-z0-9_.-]+)$`)
+
+var networkModes = map[string]bool{
+	"default":    true,
+	"restricted": true,
+}
+
+// restrictedNetworkGuestIP is the address of the user network at which the
+// HTTP server and the allowlist are exposed in the restricted network mode,
+// the host address 10.0.2.2 being unreachable.
+const restrictedNetworkGuestIP = "10.0.2.254"
+
 var diskInterface = map[string]bool{
 	"ide":         true,
 	"scsi":        true,
@@ -58,6 +74,69 @@ var diskDZeroes = map[string]bool{
 	"off":   true,
 }
 
+var usbControllers = map[string]bool{
+	"qemu-xhci":      true,
+	"nec-usb-xhci":   true,
+	"usb-ehci":       true,
+	"ich9-usb-ehci1": true,
+	"piix3-usb-uhci": true,
+	"pci-ohci":       true,
+}
+
+var inputDevices = map[string]bool{
+	"usb-tablet":          true,
+	"usb-mouse":           true,
+	"usb-kbd":             true,
+	"virtio-tablet-pci":   true,
+	"virtio-mouse-pci":    true,
+	"virtio-keyboard-pci": true,
+}
+
+var vgaTypes = map[string]bool{
+	"std":    true,
+	"cirrus": true,
+	"vmware": true,
+	"qxl":    true,
+	"virtio": true,
+	"none":   true,
+}
+
+var videoDevices = map[string]bool{
+	"virtio-gpu-pci": true,
+	"virtio-vga":     true,
+	"ramfb":          true,
+	"bochs-display":  true,
+	"qxl-vga":        true,
+	"VGA":            true,
+	"none":           true,
+}
+
+// vgaArchs are the architectures with a VGA adapter by default, which the
+// VGA compatible video devices and -vga require.
+var vgaArchs = map[string]bool{
+	"x86_64": true,
+	"i386":   true,
+	"ppc64":  true,
+}
+
+// maxSocketPathLength is the length of the longest Unix socket path, which
+// fits with its trailing NUL in the 104 bytes of sun_path on macOS and the
+// BSDs, and the 108 bytes on Linux.
+const maxSocketPathLength = 103
+
+// The video device of the architectures which have no display adapter by
+// default, like the aarch64 virt machine.
+const defaultVideoDevice = "virtio-gpu-pci"
+
+var audioDevices = map[string]bool{
+	"intel-hda":        true,
+	"ich9-intel-hda":   true,
+	"ac97":             true,
+	"es1370":           true,
+	"sb16":             true,
+	"virtio-sound-pci": true,
+}
+
 type QemuImgArgs struct {
 	Convert []string `mapstructure:"convert" required:"false"`
 	Create  []string `mapstructure:"create" required:"false"`
@@ -106,14 +185,86 @@ type Config struct {
 	// Unset by default.
 	AdditionalDiskSize []string `mapstructure:"disk_additional_size" required:"false"`
 	// The number of cpus to use when building the VM.
-	//  The default is `1` CPU.
+	//  The default is `1` CPU, or the product of `sockets`, `cores` and
+	//  `threads` when any of them is set.
 	CpuCount int `mapstructure:"cpus" required:"false"`
+	// The number of CPU sockets of the VM. When none of `sockets`, `cores`
+	// and `threads` is set, each CPU is its own socket. Otherwise the unset
+	// ones default to `1`, and `sockets` * `cores` * `threads` must be equal
+	// to `cpus`.
+	//
+	// Some guests limit the number of sockets they use, like the client
+	// editions of Windows, so a single socket with several cores is better.
+	Sockets int `mapstructure:"sockets" required:"false"`
+	// The number of cores per CPU socket. See `sockets`.
+	Cores int `mapstructure:"cores" required:"false"`
+	// The number of threads per CPU core. See `sockets`.
+	Threads int `mapstructure:"threads" required:"false"`
+	// The CPU model to emulate, set with the `-cpu` option of QEMU. Run
+	// `qemu-system-x86_64 -cpu help` for the list of models. Defaults to
+	// `host` with the `kvm` and `hvf` accelerators, which passes the host CPU
+	// through, and to `max` with `tcg`, which enables all the features QEMU
+	// can emulate. With other accelerators, the default of QEMU is used.
+	CPUModel string `mapstructure:"cpu_model" required:"false"`
+	// CPU features to enable or disable on top of `cpu_model`, like
+	// `["+vmx", "-hypervisor"]` or `["kvm=off"]`.
+	CPUFeatures []string `mapstructure:"cpu_features" required:"false"`
 	// The firmware file to be used by QEMU, which is to be set by the -bios
 	// option of QEMU. Particularly, this option can be set to use EFI instead
 	// of BIOS, by using "OVMF.fd" from OpenFirmware.
 	// If unset, no -bios option is passed to QEMU, using the default of QEMU.
 	// Also see the QEMU documentation.
 	Firmware string `mapstructure:"firmware" required:"false"`
+	// The path to the UEFI firmware code to load in flash memory, like
+	// `/usr/share/OVMF/OVMF_CODE_4M.fd`. Unlike with `firmware`, the UEFI
+	// variables are persisted, in a copy of `efi_firmware_vars` saved in the
+	// output directory as `efivars.fd`, to be shipped with the disk image.
+	// Defaults to an OVMF firmware with Secure Boot support with
+	// `secure_boot`.
+	EFIFirmwareCode string `mapstructure:"efi_firmware_code" required:"false"`
+	// The path to the UEFI variable store template matching
+	// `efi_firmware_code`, like `/usr/share/OVMF/OVMF_VARS_4M.fd`. Defaults to
+	// one in which the Microsoft keys are enrolled with `secure_boot`.
+	EFIFirmwareVars string `mapstructure:"efi_firmware_vars" required:"false"`
+	// Boot the VM with UEFI Secure Boot enabled, so that the signatures of
+	// the boot loaders are checked during the installation. This uses the
+	// `q35` machine type with SMM, which is the default `machine_type` with
+	// `secure_boot`, and an OVMF firmware built with Secure Boot support. The
+	// usual locations of the OVMF firmware of Debian, Ubuntu, Fedora, RHEL and
+	// openSUSE are searched when `efi_firmware_code` is unset. Only supported
+	// on x86_64. SMM is enabled with the `-machine` and `-global` arguments,
+	// and the firmware is attached with `-drive` arguments, which can't be
+	// set in `qemuargs` then. Defaults to `false`.
+	SecureBoot bool `mapstructure:"secure_boot" required:"false"`
+	// Custom keys to enroll in the UEFI variable store with `secure_boot`. See
+	// [Secure Boot configuration](#secure-boot-configuration).
+	SecureBootKeys *SecureBootKeysConfig `mapstructure:"secure_boot_keys" required:"false"`
+	// The path or URL to a kernel to boot directly, using the -kernel option
+	// of QEMU. This skips the boot menu of the installation media, so that
+	// the installer can be configured through `kernel_cmdline` instead of
+	// typing a `boot_command`. Like `iso_url`, remote files are downloaded
+	// and cached. Unset by default.
+	KernelPath string `mapstructure:"kernel_path" required:"false"`
+	// The checksum for the `kernel_path` file, in the same format as
+	// `iso_checksum`. Required when `kernel_path` is set; use `none` to skip
+	// the verification.
+	KernelChecksum string `mapstructure:"kernel_checksum" required:"false"`
+	// The path or URL to an initial ramdisk to load alongside `kernel_path`,
+	// using the -initrd option of QEMU. Unset by default.
+	InitrdPath string `mapstructure:"initrd_path" required:"false"`
+	// The checksum for the `initrd_path` file, in the same format as
+	// `iso_checksum`. Required when `initrd_path` is set; use `none` to skip
+	// the verification.
+	InitrdChecksum string `mapstructure:"initrd_checksum" required:"false"`
+	// The kernel command line passed with the -append option of QEMU when
+	// `kernel_path` is set. This is a template engine and allows access to
+	// the following variables: `{{ .HTTPIP }}`, `{{ .HTTPHost }}`,
+	// `{{ .HTTPPort }}` and `{{ .Name }}`. For example:
+	//
+	// ```hcl
+	//   kernel_cmdline = "auto=true priority=critical url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg"
+	// ```
+	KernelCmdline string `mapstructure:"kernel_cmdline" required:"false"`
 	// The interface to use for the disk. Allowed values include any of `ide`,
 	// `scsi`, `virtio` or `virtio-scsi`^\*. Note also that any boot commands
 	// or kickstart type scripts must have proper adjustments for resulting
@@ -175,6 +326,11 @@ type Config struct {
 	// this value is set to `true`, the machine will either clone the source or
 	// use it as a backing file (if `use_backing_file` is `true`); then, it
 	// will resize the image according to `disk_size` and boot it.
+	//
+	// When neither `ssh_password`, `ssh_private_key_file` nor
+	// `ssh_agent_auth` are set, Packer generates a cloud-init seed authorizing
+	// a temporary SSH key for `ssh_username`, so that the build can log in to
+	// a stock cloud image. See [cloud-init configuration](#cloud-init-configuration).
 	DiskImage bool `mapstructure:"disk_image" required:"false"`
 	// Only applicable when disk_image is true
 	// and format is qcow2, set this option to true to create a new QCOW2
@@ -188,9 +344,45 @@ type Config struct {
 	// flags `-machine help` to list available types for your system. This
 	// defaults to `pc`.
 	MachineType string `mapstructure:"machine_type" required:"false"`
-	// The amount of memory to use when building the VM
-	// in megabytes. This defaults to 512 megabytes.
-	MemorySize int `mapstructure:"memory" required:"false"`
+	// The amount of memory to use when building the VM. Suffix with `K`,
+	// `M`, `G` or `T` for kilobytes, megabytes, gigabytes or terabytes, like
+	// `4G`. A number without unit is in megabytes. This defaults to `512M`.
+	MemorySize string `mapstructure:"memory" required:"false"`
+	// The maximum amount of memory of the VM, up to which memory can be
+	// hotplugged while the VM runs, with the same units as `memory`. It must
+	// be greater than `memory`. Unset by default, memory can't be hotplugged.
+	MaxMemory string `mapstructure:"max_memory" required:"false"`
+	// The number of slots to hotplug memory devices into, with `max_memory`.
+	// Defaults to `1`.
+	MemorySlots int `mapstructure:"memory_slots" required:"false"`
+	// Attach a virtio balloon device, through which the guest can return
+	// unused memory to the host and report memory statistics. The device is
+	// set with the `-device` argument, which can't be set in `qemuargs` then.
+	// Defaults to `false`.
+	MemoryBalloon bool `mapstructure:"memory_balloon" required:"false"`
+	// The QEMU memory backend to allocate the memory of the VM from. Allowed
+	// values are `ram`, `file`, which maps a file in `memory_backend_path`,
+	// and `memfd`, which allocates anonymous shared memory. When unset, QEMU
+	// allocates the memory itself, unless `numa_node` is set, in which case
+	// it defaults to `ram`. This requires QEMU 5.0 or later. The backends are
+	// set with the `-object` and `-machine` arguments, or `-numa` with
+	// `numa_node`, which can't be set in `qemuargs` then.
+	MemoryBackend string `mapstructure:"memory_backend" required:"false"`
+	// The directory in which the `file` memory backend creates its file, like
+	// a hugetlbfs mount. Defaults to `/dev/hugepages` with `memory_hugepages`.
+	MemoryBackendPath string `mapstructure:"memory_backend_path" required:"false"`
+	// Back the memory with huge pages, with the `file` and `memfd` memory
+	// backends. Huge pages must be reserved on the host, for example with
+	// the `vm.nr_hugepages` sysctl. Defaults to `false`.
+	MemoryHugepages bool `mapstructure:"memory_hugepages" required:"false"`
+	// Share the memory with other processes, as needed by vhost-user
+	// devices. Defaults to `false`.
+	MemoryShare bool `mapstructure:"memory_share" required:"false"`
+	// Allocate all the memory when the VM starts, rather than on demand.
+	// Defaults to `false`.
+	MemoryPrealloc bool `mapstructure:"memory_prealloc" required:"false"`
+	// NUMA nodes of the VM. See [NUMA configuration](#numa-configuration).
+	NUMANodes []NUMANodeConfig `mapstructure:"numa_node" required:"false"`
 	// The driver to use for the network interface. Allowed values `ne2k_pci`,
 	// `i82551`, `i82557b`, `i82559er`, `rtl8139`, `e1000`, `pcnet`, `virtio`,
 	// `virtio-net`, `virtio-net-pci`, `usb-net`, `i82559a`, `i82559b`,
@@ -207,7 +399,87 @@ type Config struct {
 	// **NB** This will automatically enable the QMP socket (see QMPEnable).
 	//
 	// **NB** This only works in Linux based OSes.
+	//
+	// **NB** This can't be used with `network_interface`, use a
+	// `network_interface` block with the `bridge` backend instead.
 	NetBridge string `mapstructure:"net_bridge" required:"false"`
+	// Enables IPv6 in the guest network. With the user mode networking, the
+	// guest gets an address in the `fec0::/64` prefix and the communicator
+	// port is also forwarded from `[::1]` on the host, which requires QEMU
+	// 6.1 or later built with libslirp 4.5 or later. With `net_bridge`, the
+	// guest IPv6 address is looked up in the neighbor table of the bridge when
+	// it has no IPv4 address. Defaults to `false`.
+	//
+	// When the bridge has no IPv4 address, `{{ .HTTPIP }}` is set to an IPv6
+	// address of the bridge, without brackets. Use `{{ .HTTPHost }}` in URLs,
+	// which encloses IPv6 addresses in brackets.
+	NetIPv6 bool `mapstructure:"net_ipv6" required:"false"`
+	// The MAC address of the network interface. Defaults to an address in the
+	// QEMU `52:54:00` prefix generated from the build name and `vm_name`, so
+	// that it is the same for every build of the VM. Concurrent builds of the
+	// same source therefore get the same address: with `net_bridge`,
+	// `mac_address` must be set to run them at once.
+	// The address is recorded in the `macAddress` artifact state. Conflicts
+	// with `network_interface`, which has its own `mac_address`.
+	MACAddress string `mapstructure:"mac_address" required:"false"`
+	// Network interfaces to attach to the VM. When unset, a single interface
+	// is attached, using `net_device` and `net_bridge`. See
+	// [Network interfaces configuration](#network-interfaces-configuration).
+	NetworkInterfaces []NetworkInterfaceConfig `mapstructure:"network_interface" required:"false"`
+	// Ports of the guest to forward from the host, in addition to the
+	// communicator port. See [Port forwarding configuration](#port-forwarding-configuration).
+	PortForwards []PortForwardConfig `mapstructure:"port_forward" required:"false"`
+	// The network mode of the guest. Allowed values are `default` and
+	// `restricted`. In the `restricted` mode, the guest is isolated from the
+	// host and the internet: it can only reach the HTTP server and the
+	// `network_allowlist`, at the `10.0.2.254` address, which is the value of
+	// `{{ .HTTPIP }}`. The communicator and the `port_forward` ports are still
+	// forwarded from the host. All the network interfaces must use the `user`
+	// backend. Defaults to `default`.
+	//
+	// The connections of the guest are relayed with `socat`, or `nc` when
+	// `socat` is not installed, which must be in the `PATH` of the host.
+	NetworkMode string `mapstructure:"network_mode" required:"false"`
+	// Virtual addresses of the user network forwarding the connections of the
+	// guest to the host. See [Guest forwarding configuration](#guest-forwarding-configuration).
+	GuestForwards []GuestForwardConfig `mapstructure:"guest_forward" required:"false"`
+	// The `host:port` addresses the guest can reach in the `restricted`
+	// network mode. Each address is exposed to the guest on the same port at
+	// `10.0.2.254`, so the ports must be unique, and out of the range of the
+	// HTTP server, `http_port_min` to `http_port_max`, when it is used. For
+	// example, with `["mirror.example.com:80"]`, the guest reaches the mirror
+	// at `http://10.0.2.254:80`.
+	NetworkAllowlist []string `mapstructure:"network_allowlist" required:"false"`
+	// The sources to look the guest address up from, in order, when the
+	// communicator network interface uses the `bridge` or `tap` backend. The
+	// address is found from the MAC address of the interface. Allowed values
+	// are:
+	//
+	//   - `arp`: the ARP table of the host, which only has the guest once it
+	//     has sent traffic to the host.
+	//   - `neighbor`: the IPv6 neighbor table of the host.
+	//   - `dnsmasq`: the lease file of a dnsmasq DHCP server serving the
+	//     bridge, see `dnsmasq_lease_file`.
+	//   - `libvirt`: the lease status files of the libvirt networks, in
+	//     `/var/lib/libvirt/dnsmasq`.
+	//
+	// Defaults to `["arp"]`, or `["arp", "neighbor"]` with `net_ipv6`.
+	//
+	// The guest address is available to provisioners as the `GuestAddress`
+	// build variable. It is `10.0.2.15` with the `user` and `passt` backends.
+	GuestAddressSources []string `mapstructure:"guest_address_sources" required:"false"`
+	// The path to the dnsmasq lease file read by the `dnsmasq` guest address
+	// source. Defaults to the first existing of `/var/lib/misc/dnsmasq.leases`
+	// and `/var/lib/dnsmasq/dnsmasq.leases`.
+	DnsmasqLeaseFile string `mapstructure:"dnsmasq_lease_file" required:"false"`
+	// The fixed address of the guest, for static network setups. The guest
+	// address isn't looked up, and the communicator connects to this address
+	// unless `ssh_host` or `winrm_host` is set. It can't be used when the
+	// communicator network interface uses the `user` or `passt` backend.
+	GuestAddress string `mapstructure:"guest_address" required:"false"`
+	// The interval between the lookups of the guest address, like `5s`.
+	// Defaults to `10s`.
+	GuestAddressPollInterval time.Duration `mapstructure:"guest_address_poll_interval" required:"false"`
 	// This is the path to the directory where the
 	// resulting virtual machine will be created. This may be relative or absolute.
 	// If relative, the path is relative to the working directory when packer
@@ -315,7 +587,7 @@ type Config struct {
 	// you have the service set to listen on.
 	//
 	// This is a template engine and allows access to the following variables:
-	// `{{ .HTTPIP }}`, `{{ .HTTPPort }}`, `{{ .HTTPDir }}`,
+	// `{{ .HTTPIP }}`, `{{ .HTTPHost }}`, `{{ .HTTPPort }}`, `{{ .HTTPDir }}`,
 	// `{{ .OutputDir }}`, `{{ .Name }}`, and `{{ .SSHHostPort }}`
 	QemuArgs [][]string `mapstructure:"qemuargs" required:"false"`
 	// A map of custom arguments to pass to qemu-img commands, where the key
@@ -370,6 +642,22 @@ type Config struct {
 	// to qemu, allowing it to choose the default. This may be needed when running
 	// under macOS, and getting errors about sdl not being available.
 	UseDefaultDisplay bool `mapstructure:"use_default_display" required:"false"`
+	// The VGA adapter, passed to the QEMU `-vga` option. Allowed values are
+	// `std`, `cirrus`, `vmware`, `qxl`, `virtio` and `none`. Only available
+	// on x86 and ppc64. Defaults to the QEMU default, `std` on x86, or to
+	// `none` when `video_device` is set.
+	VGA string `mapstructure:"vga" required:"false"`
+	// The display adapter to attach as a device, for architectures without
+	// VGA or some installers failing with the default adapter. Allowed values
+	// are `virtio-gpu-pci`, `virtio-vga`, `ramfb`, `bochs-display`,
+	// `qxl-vga`, `VGA` and `none`. `virtio-vga`, `qxl-vga` and `VGA` are
+	// only available on x86 and ppc64. Defaults to `virtio-gpu-pci` with the
+	// `virt` machine types of aarch64, arm and riscv64, which have no display
+	// otherwise, so that `boot_command` can be typed over VNC, unless
+	// `qemuargs` sets `-device`, and to none with the other machine types and
+	// architectures. The adapter is set with the `-device` argument, which
+	// can't be set in `qemuargs` then.
+	VideoDevice string `mapstructure:"video_device" required:"false"`
 	// What QEMU -display option to use. Defaults to gtk, use none to not pass the
 	// -display option allowing QEMU to choose the default. This may be needed when
 	// running under macOS, and getting errors about sdl not being available.
@@ -391,6 +679,33 @@ type Config struct {
 	// vnc display address.
 	VNCPortMin int `mapstructure:"vnc_port_min" required:"false"`
 	VNCPortMax int `mapstructure:"vnc_port_max"`
+	// Make QEMU listen for VNC on a Unix socket rather than on a TCP port,
+	// so that no port is allocated and the display isn't reachable over the
+	// network. `vnc_bind_address`, `vnc_port_min` and `vnc_port_max` are
+	// then ignored. Not supported on Windows. Defaults to `false`.
+	VNCSocket bool `mapstructure:"vnc_socket" required:"false"`
+	// The path to the VNC socket with `vnc_socket`. Defaults to
+	// `output_directory`/`vm_name`.vnc. Unix socket paths are limited to 103
+	// characters.
+	VNCSocketPath string `mapstructure:"vnc_socket_path" required:"false"`
+	// The protocol of the remote display of the VM. Allowed values are `vnc`
+	// and `spice`. With `spice`, a SPICE server is started for clients like
+	// `remote-viewer`, while VNC is still used to type the `boot_command`.
+	// The address of the SPICE server is available as the `SPICEAddress`
+	// build variable. Defaults to `vnc`.
+	DisplayProtocol string `mapstructure:"display_protocol" required:"false"`
+	// The IP address the SPICE server binds to. Defaults to `127.0.0.1`.
+	SPICEBindAddress string `mapstructure:"spice_bind_address" required:"false"`
+	// Whether or not to set a password on the SPICE server, which is
+	// available as the `SPICEPassword` build variable. This option
+	// automatically enables the QMP socket. Defaults to `false`.
+	SPICEUsePassword bool `mapstructure:"spice_use_password" required:"false"`
+	// The minimum and maximum port to use for the SPICE server. Packer uses a
+	// randomly chosen port in this range that appears available. By default
+	// this is 5900 to 6000, the ports used for VNC being excluded. The
+	// minimum and maximum ports are inclusive.
+	SPICEPortMin int `mapstructure:"spice_port_min" required:"false"`
+	SPICEPortMax int `mapstructure:"spice_port_max"`
 	// This is the name of the image (QCOW2 or IMG) file for
 	// the new virtual machine. By default this is packer-BUILDNAME, where
 	// "BUILDNAME" is the name of the build. Currently, no file extension will be
@@ -401,10 +716,68 @@ type Config struct {
 	// `virtio-scsi`. The Qemu builder uses `virtio` by default.
 	// Some ARM64 images require `virtio-scsi`.
 	CDROMInterface string `mapstructure:"cdrom_interface" required:"false"`
+	// The USB controller to attach to the VM. Allowed values are `qemu-xhci`,
+	// `nec-usb-xhci`, `usb-ehci`, `ich9-usb-ehci1`, `piix3-usb-uhci` and
+	// `pci-ohci`. Defaults to `qemu-xhci` when `input_devices` has a USB
+	// device, and to no controller otherwise. The controller, the input
+	// devices and the sound card are set with the `-device` argument, which
+	// can't be set in `qemuargs` with them.
+	USBController string `mapstructure:"usb_controller" required:"false"`
+	// The input devices to attach to the VM. Allowed values are `usb-tablet`,
+	// `usb-mouse`, `usb-kbd`, `virtio-tablet-pci`, `virtio-mouse-pci` and
+	// `virtio-keyboard-pci`. A tablet reports absolute positions, which keeps
+	// the pointer in sync over VNC, as needed by some Windows installers.
+	// For example:
+	//
+	// ```hcl
+	//   input_devices = ["usb-tablet", "usb-kbd"]
+	// ```
+	InputDevices []string `mapstructure:"input_devices" required:"false"`
+	// The sound card to attach to the VM, for guests which require one.
+	// Allowed values are `intel-hda`, `ich9-intel-hda`, `ac97`, `es1370`,
+	// `sb16` and `virtio-sound-pci`. The HDA controllers get a `hda-duplex`
+	// codec. The sound is discarded by the `none` audio backend. This
+	// requires QEMU 4.2 or later, and QEMU 8.2 for `virtio-sound-pci`.
+	AudioDevice string `mapstructure:"audio_device" required:"false"`
+	// Attach a `virtio-rng-pci` device feeding the guest with entropy from
+	// `/dev/urandom` on the host, so that minimal guests don't block in early
+	// boot waiting for entropy. The device is set with the `-object` and
+	// `-device` arguments, which can't be set in `qemuargs` then. Defaults to
+	// `true` with the `q35`, `pc-q35-*` and `virt*` machine types, unless
+	// `qemuargs` sets `-object` or `-device`, and to `false` otherwise.
+	RNG config.Trilean `mapstructure:"rng" required:"false"`
+	// The maximum number of bytes of entropy the guest can read from the
+	// `rng` device per `rng_period`. Defaults to `0`, no limit.
+	RNGMaxBytes int `mapstructure:"rng_max_bytes" required:"false"`
+	// The period over which `rng_max_bytes` is counted, as a duration string
+	// with a precision of a millisecond. Defaults to `1s` with
+	// `rng_max_bytes`.
+	RNGPeriod time.Duration `mapstructure:"rng_period" required:"false"`
+	// Generate a cloud-init NoCloud seed and attach it to the VM. See
+	// [cloud-init configuration](#cloud-init-configuration) for the available
+	// settings.
+	CloudInit *CloudInitConfig `mapstructure:"cloud_init" required:"false"`
+	// Pass an Ignition config to the VM, for Fedora CoreOS and Flatcar
+	// Container Linux builds. See [Ignition configuration](#ignition-configuration)
+	// for the available settings. Conflicts with `cloud_init`.
+	Ignition *IgnitionConfig `mapstructure:"ignition_config" required:"false"`
+	// Items to pass to the guest through the QEMU firmware configuration
+	// device. See [fw_cfg and SMBIOS configuration](#fw_cfg-and-smbios-configuration).
+	FwCfg []FwCfgConfig `mapstructure:"fw_cfg" required:"false"`
+	// Structures to add to the SMBIOS tables of the VM. See
+	// [fw_cfg and SMBIOS configuration](#fw_cfg-and-smbios-configuration).
+	SMBIOS []SMBIOSConfig `mapstructure:"smbios" required:"false"`
+	// Host directories to share with the guest through virtio-fs or 9p. See
+	// [Shared folders configuration](#shared-folders-configuration).
+	SharedFolders []SharedFolderConfig `mapstructure:"shared_folder" required:"false"`
 
 	// TODO(mitchellh): deprecate
 	RunOnce bool `mapstructure:"run_once"`
 
+	// The memory and max_memory in megabytes, set by Prepare.
+	memoryMiB    int
+	maxMemoryMiB int
+
 	ctx interpolate.Context
 }
 
@@ -416,6 +789,10 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
 				"boot_command",
+				"cloud_init",
+				"fw_cfg",
+				"ignition_config",
+				"kernel_cmdline",
 				"qemuargs",
 			},
 		},
@@ -485,6 +862,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 
 	if c.MachineType == "" {
 		c.MachineType = "pc"
+		if c.SecureBoot {
+			c.MachineType = "q35"
+		}
 	}
 
 	if c.OutputDir == "" {
@@ -495,9 +875,66 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.QemuBinary = "qemu-system-x86_64"
 	}
 
-	if c.MemorySize < 10 {
-		log.Printf("MemorySize %d is too small, using default: 512", c.MemorySize)
-		c.MemorySize = 512
+	if c.MemorySize == "" {
+		c.MemorySize = "512M"
+	}
+	if memory, err := parseMemorySize(c.MemorySize); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("memory %s", err))
+	} else if memory < 10 {
+		warnings = append(warnings, fmt.Sprintf("memory %s is too small, using the default of 512M", c.MemorySize))
+		c.MemorySize = "512M"
+		c.memoryMiB = 512
+	} else {
+		c.MemorySize = fmt.Sprintf("%dM", memory)
+		c.memoryMiB = memory
+	}
+
+	if c.MaxMemory != "" {
+		if maxMemory, err := parseMemorySize(c.MaxMemory); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, fmt.Errorf("max_memory %s", err))
+		} else if maxMemory <= c.memoryMiB {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("max_memory (%s) must be greater than memory (%s)", c.MaxMemory, c.MemorySize))
+		} else {
+			c.MaxMemory = fmt.Sprintf("%dM", maxMemory)
+			c.maxMemoryMiB = maxMemory
+		}
+		if c.MemorySlots == 0 {
+			c.MemorySlots = 1
+		}
+		if c.MemorySlots < 1 || c.MemorySlots > 256 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("memory_slots must be between 1 and 256"))
+		}
+	} else if c.MemorySlots != 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("memory_slots can only be used with max_memory"))
+	}
+	if c.MemoryBalloon {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("memory_balloon", "-device")...)
+	}
+
+	if c.Sockets != 0 || c.Cores != 0 || c.Threads != 0 {
+		if c.Sockets < 0 || c.Cores < 0 || c.Threads < 0 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("sockets, cores and threads must be positive"))
+		}
+		if c.Sockets == 0 {
+			c.Sockets = 1
+		}
+		if c.Cores == 0 {
+			c.Cores = 1
+		}
+		if c.Threads == 0 {
+			c.Threads = 1
+		}
+		topologyCPUs := c.Sockets * c.Cores * c.Threads
+		if c.CpuCount == 0 {
+			c.CpuCount = topologyCPUs
+		} else if c.CpuCount != topologyCPUs {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("sockets (%d) * cores (%d) * threads (%d) must be equal to cpus (%d)", c.Sockets, c.Cores, c.Threads, c.CpuCount))
+		}
 	}
 
 	if c.CpuCount < 1 {
@@ -505,6 +942,131 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.CpuCount = 1
 	}
 
+	sharedFolderNames := make(map[string]bool)
+	sharedFolderTags := make(map[string]bool)
+	virtiofs, ninep := false, false
+	for i := range c.SharedFolders {
+		sf := &c.SharedFolders[i]
+		errs = packersdk.MultiErrorAppend(errs, sf.Prepare()...)
+		if sharedFolderNames[sf.Name] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("shared_folder name %q is used more than once", sf.Name))
+		}
+		sharedFolderNames[sf.Name] = true
+		if sharedFolderTags[sf.Tag] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("shared_folder tag %q is used more than once", sf.Tag))
+		}
+		sharedFolderTags[sf.Tag] = true
+		switch sf.Type {
+		case "virtiofs":
+			virtiofs = true
+		case "9p":
+			ninep = true
+		}
+	}
+	if virtiofs {
+		if runtime.GOOS != "linux" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("the virtiofs shared_folder type is only supported in Linux based OSes"))
+		}
+		// virtiofsd accesses the memory of the VM, which must be backed by a
+		// file descriptor it can map.
+		if c.MemoryBackend == "" {
+			c.MemoryBackend = "memfd"
+		}
+		if c.MemoryBackend == "ram" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("the virtiofs shared_folder type requires the file or memfd memory_backend"))
+		}
+		c.MemoryShare = true
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("the virtiofs shared_folder type", "-chardev", "-device")...)
+	}
+	if ninep {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("the 9p shared_folder type", "-fsdev", "-device")...)
+	}
+
+	if c.MemoryBackend == "" && len(c.NUMANodes) > 0 {
+		c.MemoryBackend = "ram"
+	}
+	if c.MemoryBackend == "file" && c.MemoryBackendPath == "" && c.MemoryHugepages {
+		c.MemoryBackendPath = defaultHugepagesPath
+	}
+	if c.MemoryBackend != "" && !memoryBackends[c.MemoryBackend] {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("memory_backend %q is not supported, only ram, file and memfd are allowed", c.MemoryBackend))
+	}
+	if c.MemoryBackend == "file" && c.MemoryBackendPath == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("memory_backend_path must be set with the file memory_backend"))
+	}
+	if c.MemoryBackend != "file" && c.MemoryBackendPath != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("memory_backend_path can only be used with the file memory_backend"))
+	}
+	if c.MemoryHugepages && c.MemoryBackend != "file" && c.MemoryBackend != "memfd" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("memory_hugepages requires the file or memfd memory_backend"))
+	}
+	if (c.MemoryShare || c.MemoryPrealloc) && c.MemoryBackend == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("memory_share and memory_prealloc require a memory_backend"))
+	}
+	// The backends are -object arguments, referenced by the NUMA nodes, or by
+	// the machine without them
+	if len(c.NUMANodes) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("numa_node", "-object", "-numa")...)
+	} else if virtiofs {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("the virtiofs shared_folder type", "-object", "-machine")...)
+	} else if c.MemoryBackend != "" {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("memory_backend", "-object", "-machine")...)
+	}
+
+	if len(c.NUMANodes) > 0 {
+		numaMemory := 0
+		cpuNodes := make(map[int]int)
+		for i := range c.NUMANodes {
+			node := &c.NUMANodes[i]
+			errs = packersdk.MultiErrorAppend(errs, node.Prepare(c.CpuCount)...)
+			numaMemory += node.memoryMiB
+			cpus, _ := parseCPUList(node.CPUs, c.CpuCount)
+			for _, cpu := range cpus {
+				if other, ok := cpuNodes[cpu]; ok {
+					errs = packersdk.MultiErrorAppend(
+						errs, fmt.Errorf("CPU %d is assigned to the NUMA nodes %d and %d", cpu, other, i))
+				}
+				cpuNodes[cpu] = i
+			}
+		}
+		if numaMemory != c.memoryMiB {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("the memory of the NUMA nodes (%dM) must add up to memory (%s)", numaMemory, c.MemorySize))
+		}
+		if len(cpuNodes) != c.CpuCount {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("all the %d CPUs must be assigned to a NUMA node, %d are", c.CpuCount, len(cpuNodes)))
+		}
+	}
+
+	if c.CPUModel == "" {
+		switch c.Accelerator {
+		case "kvm", "hvf":
+			c.CPUModel = "host"
+		case "tcg":
+			c.CPUModel = "max"
+		}
+	}
+	for _, feature := range c.CPUFeatures {
+		if !cpuFeatureRe.MatchString(feature) {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("cpu_features %q is invalid, use +feature, -feature or feature=value", feature))
+		}
+	}
+	if len(c.CPUFeatures) > 0 && c.CPUModel == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("cpu_features requires cpu_model with the %s accelerator", c.Accelerator))
+	}
+
 	if c.VNCBindAddress == "" {
 		c.VNCBindAddress = "127.0.0.1"
 	}
@@ -517,10 +1079,113 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.VNCPortMax = 6000
 	}
 
+	if c.DisplayProtocol == "" {
+		c.DisplayProtocol = "vnc"
+	}
+
+	if c.SPICEBindAddress == "" {
+		c.SPICEBindAddress = "127.0.0.1"
+	}
+
+	if c.SPICEPortMin == 0 {
+		c.SPICEPortMin = 5900
+	}
+
+	if c.SPICEPortMax == 0 {
+		c.SPICEPortMax = 6000
+	}
+
 	if c.VMName == "" {
 		c.VMName = fmt.Sprintf("packer-%s", c.PackerBuildName)
 	}
 
+	arch := qemuArch(c.QemuBinary)
+	// The adapter is a -device argument, which qemuargs would replace
+	if c.VideoDevice != "" && c.VideoDevice != "none" {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("video_device", "-device")...)
+	}
+	// The virt machines have no display, unlike the boards emulated on the
+	// same architectures, like raspi3b or sifive_u, which can't take PCI
+	// devices
+	if c.VGA == "" && c.VideoDevice == "" && (arch == "aarch64" || arch == "arm" || arch == "riscv64") &&
+		strings.HasPrefix(c.MachineType, "virt") && !c.qemuArgsSet("-device") {
+		c.VideoDevice = defaultVideoDevice
+	}
+	if c.VGA == "" && c.VideoDevice != "" && c.VideoDevice != "none" && vgaArchs[arch] {
+		// Only attach one display adapter
+		c.VGA = "none"
+	}
+	if c.VGA != "" {
+		if !vgaTypes[c.VGA] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("vga %q is not supported, only std, cirrus, vmware, qxl, virtio and none are allowed", c.VGA))
+		} else if !vgaArchs[arch] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("vga is not supported on %s, use video_device instead", arch))
+		}
+	}
+	if c.VideoDevice != "" {
+		if !videoDevices[c.VideoDevice] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("video_device %q is not supported, only virtio-gpu-pci, virtio-vga, ramfb, bochs-display, qxl-vga, VGA and none are allowed", c.VideoDevice))
+		} else if (c.VideoDevice == "virtio-vga" || c.VideoDevice == "qxl-vga" || c.VideoDevice == "VGA") && !vgaArchs[arch] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("video_device %s is not supported on %s", c.VideoDevice, arch))
+		}
+	}
+
+	if c.SecureBoot {
+		if c.MachineType != "q35" && !strings.HasPrefix(c.MachineType, "pc-q35-") {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("secure_boot requires the q35 machine_type, not %s", c.MachineType))
+		}
+		if arch != "x86_64" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("secure_boot is only supported on x86_64, not %s", arch))
+		}
+		// SMM is enabled on the -machine argument, the firmware flash is
+		// secured with a -global argument, and attached with -drive arguments
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("secure_boot", "-machine", "-global", "-drive")...)
+		if c.EFIFirmwareCode == "" && c.EFIFirmwareVars == "" {
+			for _, firmware := range secureBootFirmwares {
+				_, codeErr := os.Stat(firmware.Code)
+				_, varsErr := os.Stat(firmware.Vars)
+				if codeErr == nil && varsErr == nil {
+					c.EFIFirmwareCode = firmware.Code
+					c.EFIFirmwareVars = firmware.Vars
+					break
+				}
+			}
+			if c.EFIFirmwareCode == "" {
+				errs = packersdk.MultiErrorAppend(
+					errs, errors.New("could not find an OVMF firmware with Secure Boot support, set efi_firmware_code and efi_firmware_vars"))
+			}
+		}
+	} else if c.SecureBootKeys != nil {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("secure_boot_keys requires secure_boot"))
+	}
+	if c.SecureBootKeys != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.SecureBootKeys.Prepare(c.VMName)...)
+	}
+	if (c.EFIFirmwareCode == "") != (c.EFIFirmwareVars == "") {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("efi_firmware_code and efi_firmware_vars must be set together"))
+	}
+	if c.EFIFirmwareCode != "" && c.Firmware != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("firmware can't be used with efi_firmware_code and efi_firmware_vars"))
+	}
+	for _, path := range []string{c.EFIFirmwareCode, c.EFIFirmwareVars} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("the EFI firmware %s is not accessible: %s", path, err))
+		}
+	}
+
 	if c.Format == "" {
 		c.Format = "qcow2"
 	}
@@ -551,6 +1216,196 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 	warnings = append(warnings, commConfigWarnings...)
 
+	// Cloud images have no user Packer can log in with, unless one is
+	// created by cloud-init. When no credentials are configured, generate a
+	// seed authorizing the temporary SSH key for ssh_username.
+	comm := &c.CommConfig.Comm
+	if c.DiskImage && c.CloudInit == nil && c.Ignition == nil && comm.Type == "ssh" &&
+		comm.SSHPassword == "" && comm.SSHPrivateKeyFile == "" && !comm.SSHAgentAuth {
+		log.Printf("No SSH credentials configured for disk_image, generating a cloud-init seed for %s", comm.SSHUsername)
+		c.CloudInit = &CloudInitConfig{implied: true}
+	}
+
+	if c.CloudInit != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.CloudInit.Prepare(comm)...)
+	}
+
+	if c.Ignition != nil {
+		errs = packersdk.MultiErrorAppend(errs, c.Ignition.Prepare()...)
+		if c.CloudInit != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("cloud_init and ignition_config can't be used together"))
+		}
+	}
+
+	for i := range c.FwCfg {
+		errs = packersdk.MultiErrorAppend(errs, c.FwCfg[i].Prepare()...)
+	}
+
+	for i := range c.SMBIOS {
+		errs = packersdk.MultiErrorAppend(errs, c.SMBIOS[i].Prepare()...)
+	}
+
+	if len(c.NetworkInterfaces) > 0 && c.NetBridge != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("net_bridge can't be used with network_interface, use the bridge backend instead"))
+	}
+	if len(c.NetworkInterfaces) > 0 && c.MACAddress != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("mac_address can't be used with network_interface, set it in the network_interface blocks instead"))
+	}
+
+	if c.MACAddress == "" {
+		c.MACAddress = generateMACAddress(c.PackerBuildName, c.VMName, 0)
+	} else if err := checkMACAddress(c.MACAddress); err != nil {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("mac_address %q is invalid: %s", c.MACAddress, err))
+	}
+
+	commInterfaces := 0
+	for i := range c.NetworkInterfaces {
+		if c.NetworkInterfaces[i].MACAddress == "" {
+			c.NetworkInterfaces[i].MACAddress = generateMACAddress(c.PackerBuildName, c.VMName, i)
+		}
+		errs = packersdk.MultiErrorAppend(errs, c.NetworkInterfaces[i].Prepare(c.NetDevice)...)
+		if c.NetworkInterfaces[i].Backend == "passt" && c.NetIPv6 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("net_ipv6 can't be used with the passt network_interface backend, which configures IPv6 by itself"))
+		}
+		if c.NetworkInterfaces[i].Communicator {
+			commInterfaces++
+		}
+	}
+	if commInterfaces > 1 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("communicator can only be set on one network_interface"))
+	} else if commInterfaces == 0 && len(c.NetworkInterfaces) > 0 {
+		c.NetworkInterfaces[0].Communicator = true
+	}
+
+	portForwardNames := make(map[string]bool)
+	for i := range c.PortForwards {
+		errs = packersdk.MultiErrorAppend(errs, c.PortForwards[i].Prepare(c.CommConfig.HostPortMin, c.CommConfig.HostPortMax)...)
+		if portForwardNames[c.PortForwards[i].Name] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("port_forward name %q is used more than once", c.PortForwards[i].Name))
+		}
+		portForwardNames[c.PortForwards[i].Name] = true
+	}
+	if len(c.PortForwards) > 0 && c.portForwardNetdevID() == "" {
+		if iface, _ := c.commNetworkInterface(); iface.Backend == "passt" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("port_forward can't be used with the passt network_interface backend, add a network interface with the user backend"))
+		} else {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("port_forward requires a network interface with the user backend"))
+		}
+	}
+
+	if c.NetworkMode == "" {
+		c.NetworkMode = "default"
+	}
+	if !networkModes[c.NetworkMode] {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("network_mode %q is not supported, only default and restricted are allowed", c.NetworkMode))
+	}
+	if c.NetworkMode == "restricted" {
+		for _, iface := range c.networkInterfaces() {
+			if iface.Backend != "user" {
+				errs = packersdk.MultiErrorAppend(
+					errs, errors.New("the restricted network_mode requires all the network interfaces to use the user backend"))
+				break
+			}
+		}
+	} else if len(c.NetworkAllowlist) > 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("network_allowlist can only be used with the restricted network_mode"))
+	}
+	allowlistPorts := make(map[string]bool)
+	for _, address := range c.NetworkAllowlist {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("network_allowlist address %q is invalid: %s", address, err))
+			continue
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("network_allowlist address %q has an invalid port", address))
+			continue
+		}
+		if allowlistPorts[port] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("network_allowlist port %s is used more than once", port))
+		}
+		allowlistPorts[port] = true
+		// The HTTP server is exposed on its own port at the same address
+		if p, _ := strconv.Atoi(port); (c.HTTPDir != "" || len(c.HTTPContent) > 0) && p >= c.HTTPPortMin && p <= c.HTTPPortMax {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("network_allowlist port %s may be used by the HTTP server, it must be out of http_port_min and http_port_max", port))
+		}
+	}
+
+	guestForwardNames := make(map[string]bool)
+	guestForwardAddresses := make(map[string]bool)
+	for i := range c.GuestForwards {
+		gf := &c.GuestForwards[i]
+		errs = packersdk.MultiErrorAppend(errs, gf.Prepare()...)
+		if guestForwardNames[gf.Name] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_forward name %q is used more than once", gf.Name))
+		}
+		if guestForwardAddresses[gf.GuestAddress] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_forward guest_address %q is used more than once", gf.GuestAddress))
+		}
+		if host, _, err := net.SplitHostPort(gf.GuestAddress); err == nil && c.NetworkMode == "restricted" && host == restrictedNetworkGuestIP {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_forward %q: %s is reserved by the restricted network_mode", gf.Name, restrictedNetworkGuestIP))
+		}
+		guestForwardNames[gf.Name] = true
+		guestForwardAddresses[gf.GuestAddress] = true
+	}
+	if len(c.GuestForwards) > 0 && c.portForwardNetdevID() == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("guest_forward requires a network interface with the user backend"))
+	}
+
+	commInterface, _ := c.commNetworkInterface()
+	if (commInterface.Backend == "socket" || commInterface.Backend == "vde") && comm.Type != "none" && comm.Host() == "" && c.GuestAddress == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("the guest address can't be found with the %s backend, guest_address, ssh_host or winrm_host must be set", commInterface.Backend))
+	}
+
+	if len(c.GuestAddressSources) == 0 {
+		c.GuestAddressSources = []string{"arp"}
+		if c.NetIPv6 {
+			c.GuestAddressSources = append(c.GuestAddressSources, "neighbor")
+		}
+	}
+	for _, source := range c.GuestAddressSources {
+		if !guestAddressSources[source] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_address_sources %q is not supported, only arp, neighbor, dnsmasq and libvirt are allowed", source))
+		}
+	}
+	if c.GuestAddressPollInterval == 0 {
+		c.GuestAddressPollInterval = 10 * time.Second
+	} else if c.GuestAddressPollInterval < 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("guest_address_poll_interval must be positive"))
+	}
+	if c.GuestAddress != "" {
+		if commInterface.Backend == "user" || commInterface.Backend == "passt" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_address can't be used with the %s backend of the communicator network interface", commInterface.Backend))
+		}
+		if net.ParseIP(strings.SplitN(c.GuestAddress, "%", 2)[0]) == nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("guest_address %q is not a valid IP address", c.GuestAddress))
+		}
+	}
+
 	if !(c.Format == "qcow2" || c.Format == "raw") {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("invalid format, only 'qcow2' or 'raw' are allowed"))
@@ -574,11 +1429,86 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			errs, errors.New("skip_resize_disk can only be used when disk_image is true"))
 	}
 
+	if c.KernelPath == "" {
+		if c.InitrdPath != "" || c.KernelCmdline != "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("initrd_path and kernel_cmdline can only be used when kernel_path is set"))
+		}
+	} else if c.KernelChecksum == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("A checksum must be specified for kernel_path; use 'none' to skip the verification"))
+	}
+
+	if c.InitrdPath != "" && c.InitrdChecksum == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("A checksum must be specified for initrd_path; use 'none' to skip the verification"))
+	}
+
 	if _, ok := accels[c.Accelerator]; !ok {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("invalid accelerator, only 'kvm', 'tcg', 'xen', 'hax', 'hvf', 'whpx', or 'none' are allowed"))
 	}
 
+	usbInputDevice := false
+	for _, device := range c.InputDevices {
+		if !inputDevices[device] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("input_devices %q is not supported, only usb-tablet, usb-mouse, usb-kbd, virtio-tablet-pci, virtio-mouse-pci and virtio-keyboard-pci are allowed", device))
+		}
+		if strings.HasPrefix(device, "usb-") {
+			usbInputDevice = true
+		}
+	}
+	// The devices are -device arguments, which qemuargs would replace
+	if len(c.InputDevices) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("input_devices", "-device")...)
+	}
+	if c.USBController != "" {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("usb_controller", "-device")...)
+	}
+	if c.AudioDevice != "" {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("audio_device", "-device")...)
+	}
+	if c.USBController == "" && usbInputDevice {
+		c.USBController = "qemu-xhci"
+	}
+	if c.USBController != "" && !usbControllers[c.USBController] {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("usb_controller %q is not supported, only qemu-xhci, nec-usb-xhci, usb-ehci, ich9-usb-ehci1, piix3-usb-uhci and pci-ohci are allowed", c.USBController))
+	}
+	if c.AudioDevice != "" && !audioDevices[c.AudioDevice] {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("audio_device %q is not supported, only intel-hda, ich9-intel-hda, ac97, es1370, sb16 and virtio-sound-pci are allowed", c.AudioDevice))
+	}
+
+	// The RNG is set with the -object and -device arguments, which qemuargs
+	// would replace
+	if c.RNG == config.TriUnset {
+		modern := c.MachineType == "q35" || strings.HasPrefix(c.MachineType, "pc-q35-") ||
+			strings.HasPrefix(c.MachineType, "virt")
+		c.RNG = config.TrileanFromBool(modern && !c.qemuArgsSet("-object") && !c.qemuArgsSet("-device"))
+	} else if c.RNG.True() {
+		errs = packersdk.MultiErrorAppend(errs, c.qemuArgsConflicts("rng", "-object", "-device")...)
+	}
+	if c.RNGMaxBytes < 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("rng_max_bytes must be positive"))
+	}
+	if c.RNGPeriod == 0 && c.RNGMaxBytes > 0 {
+		c.RNGPeriod = time.Second
+	}
+	if c.RNGPeriod != 0 && c.RNGMaxBytes == 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("rng_period requires rng_max_bytes"))
+	} else if c.RNGPeriod != 0 && c.RNGPeriod < time.Millisecond {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("rng_period must be at least 1ms"))
+	}
+	if (c.RNGMaxBytes != 0 || c.RNGPeriod != 0) && c.RNG.False() {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("rng_max_bytes and rng_period can't be used without rng"))
+	}
+
 	if _, ok := diskInterface[c.DiskInterface]; !ok {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("unrecognized disk interface type"))
@@ -622,15 +1552,70 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 			errs, fmt.Errorf("vnc_port_min must be less than vnc_port_max"))
 	}
 
+	if c.VNCSocket && runtime.GOOS == "windows" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("vnc_socket is not supported on Windows"))
+	}
+
+	if c.VNCSocketPath != "" && !c.VNCSocket {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("vnc_socket_path requires vnc_socket"))
+	}
+
+	if c.DisplayProtocol != "vnc" && c.DisplayProtocol != "spice" {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("display_protocol %q is not supported, only vnc and spice are allowed", c.DisplayProtocol))
+	}
+
+	if c.SPICEUsePassword && c.DisplayProtocol != "spice" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("spice_use_password requires the spice display_protocol"))
+	}
+
+	if c.SPICEPortMin < 1024 || c.SPICEPortMin > 65535 || c.SPICEPortMax > 65535 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("spice_port_min and spice_port_max must be between 1024 and 65535"))
+	}
+
+	if c.SPICEPortMin > c.SPICEPortMax {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("spice_port_min must be less than spice_port_max"))
+	}
+
 	if c.NetBridge != "" && runtime.GOOS != "linux" {
 		errs = packersdk.MultiErrorAppend(
 			errs, fmt.Errorf("net_bridge is only supported in Linux based OSes"))
 	}
+	for _, iface := range c.NetworkInterfaces {
+		if iface.Backend == "bridge" && runtime.GOOS != "linux" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("the bridge network_interface backend is only supported in Linux based OSes"))
+			break
+		}
+	}
+	for _, iface := range c.NetworkInterfaces {
+		if iface.Backend == "passt" && runtime.GOOS != "linux" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("the passt network_interface backend is only supported in Linux based OSes"))
+			break
+		}
+	}
 
-	if c.NetBridge != "" || c.VNCUsePassword {
+	// The guest address is looked up from the MAC address of the interface,
+	// which is retrieved through QMP.
+	if commInterface.Backend == "bridge" || commInterface.Backend == "tap" || c.VNCUsePassword || c.SPICEUsePassword {
 		c.QMPEnable = true
 	}
 
+	if c.VNCSocket && c.VNCSocketPath == "" {
+		socketName := fmt.Sprintf("%s.vnc", c.VMName)
+		c.VNCSocketPath = filepath.Join(c.OutputDir, socketName)
+	}
+	if len(c.VNCSocketPath) > maxSocketPathLength {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("vnc_socket_path %s is %d characters long, Unix socket paths are limited to %d: set a shorter vnc_socket_path or output_directory", c.VNCSocketPath, len(c.VNCSocketPath), maxSocketPathLength))
+	}
+
 	if c.QMPEnable && c.QMPSocketPath == "" {
 		socketName := fmt.Sprintf("%s.monitor", c.VMName)
 		c.QMPSocketPath = filepath.Join(c.OutputDir, socketName)
@@ -647,3 +1632,112 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	return warnings, nil
 
 }
+
+// networkInterfaces returns the network interfaces attached to the VM,
+// defaulting to a single interface configured by net_device and net_bridge.
+func (c *Config) networkInterfaces() []NetworkInterfaceConfig {
+	if len(c.NetworkInterfaces) > 0 {
+		return c.NetworkInterfaces
+	}
+
+	iface := NetworkInterfaceConfig{
+		Backend:      "user",
+		Model:        c.NetDevice,
+		MACAddress:   c.MACAddress,
+		Communicator: true,
+	}
+	if c.NetBridge != "" {
+		iface.Backend = "bridge"
+		iface.Bridge = c.NetBridge
+	}
+	return []NetworkInterfaceConfig{iface}
+}
+
+// commNetworkInterface returns the network interface the communicator
+// connects through, and its netdev id.
+func (c *Config) commNetworkInterface() (NetworkInterfaceConfig, string) {
+	ifaces := c.networkInterfaces()
+	for i, iface := range ifaces {
+		if iface.Communicator {
+			return iface, netdevID(i)
+		}
+	}
+	return ifaces[0], netdevID(0)
+}
+
+// portForwardNetdevID returns the netdev id of the network interface the
+// port forwards are set up on: the communicator one when it uses the user
+// backend, or the first one using it. It is empty without such interface.
+func (c *Config) portForwardNetdevID() string {
+	if iface, id := c.commNetworkInterface(); iface.Backend == "user" {
+		return id
+	}
+	for i, iface := range c.networkInterfaces() {
+		if iface.Backend == "user" {
+			return netdevID(i)
+		}
+	}
+	return ""
+}
+
+// qemuArgsConflicts returns an error for each of keys set in qemuargs, which
+// replaces the arguments the builder generates for option.
+func (c *Config) qemuArgsConflicts(option string, keys ...string) []error {
+	var errs []error
+	for _, key := range keys {
+		if c.qemuArgsSet(key) {
+			errs = append(errs, fmt.Errorf("%s can't be used with %s in qemuargs, which replaces the arguments it requires", option, key))
+		}
+	}
+	return errs
+}
+
+// qemuArgsSet returns whether key is set in qemuargs.
+func (c *Config) qemuArgsSet(key string) bool {
+	for _, args := range c.QemuArgs {
+		if len(args) > 0 && args[0] == key {
+			return true
+		}
+	}
+	return false
+}
+
+// guestForwardAddresses returns the guest addresses of the guest forwards by
+// name, for templates.
+func (c *Config) guestForwardAddresses() map[string]string {
+	addresses := make(map[string]string, len(c.GuestForwards))
+	for _, gf := range c.GuestForwards {
+		addresses[gf.Name] = gf.GuestAddress
+	}
+	return addresses
+}
+
+// generateMACAddress returns a MAC address in the QEMU 52:54:00 prefix,
+// derived from the build name, the VM name and the index of the interface.
+// The build name tells apart the sources of a template sharing a vm_name.
+func generateMACAddress(buildName string, vmName string, index int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", buildName, vmName, index)))
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", sum[0], sum[1], sum[2])
+}
+
+// qemuArch returns the architecture emulated by a qemu-system binary. For
+// binaries not following the qemu-system-<arch> naming, like qemu-kvm, the
+// architecture of the build machine is assumed.
+func qemuArch(binary string) string {
+	name := strings.TrimSuffix(filepath.Base(binary), ".exe")
+	if strings.HasPrefix(name, "qemu-system-") {
+		return strings.TrimPrefix(name, "qemu-system-")
+	}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "386":
+		return "i386"
+	case "arm64":
+		return "aarch64"
+	case "ppc64le":
+		return "ppc64"
+	}
+	return runtime.GOARCH
+}
@@ -7,132 +7,213 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
+// FlatCloudInitConfig is an auto-generated flat version of CloudInitConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatCloudInitConfig struct {
+	UserData      *string `mapstructure:"user_data" required:"false" cty:"user_data" hcl:"user_data"`
+	MetaData      *string `mapstructure:"meta_data" required:"false" cty:"meta_data" hcl:"meta_data"`
+	NetworkConfig *string `mapstructure:"network_config" required:"false" cty:"network_config" hcl:"network_config"`
+}
+
+// FlatMapstructure returns a new FlatCloudInitConfig.
+// FlatCloudInitConfig is an auto-generated flat version of CloudInitConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*CloudInitConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatCloudInitConfig)
+}
+
+// HCL2Spec returns the hcl spec of a CloudInitConfig.
+// This spec is used by HCL to read the fields of CloudInitConfig.
+// The decoded values from this spec will then be applied to a FlatCloudInitConfig.
+func (*FlatCloudInitConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"user_data":      &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
+		"meta_data":      &hcldec.AttrSpec{Name: "meta_data", Type: cty.String, Required: false},
+		"network_config": &hcldec.AttrSpec{Name: "network_config", Type: cty.String, Required: false},
+	}
+	return s
+}
+
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	HTTPDir                   *string           `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
-	HTTPContent               map[string]string `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
-	HTTPPortMin               *int              `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
-	HTTPPortMax               *int              `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
-	HTTPAddress               *string           `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
-	HTTPInterface             *string           `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
-	ISOChecksum               *string           `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
-	RawSingleISOUrl           *string           `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
-	ISOUrls                   []string          `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
-	TargetPath                *string           `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
-	TargetExtension           *string           `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
-	BootGroupInterval         *string           `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
-	BootWait                  *string           `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	BootCommand               []string          `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
-	DisableVNC                *bool             `mapstructure:"disable_vnc" cty:"disable_vnc" hcl:"disable_vnc"`
-	BootKeyInterval           *string           `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
-	ShutdownCommand           *string           `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
-	ShutdownTimeout           *string           `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
-	Type                      *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string           `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int              `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	HostPortMin               *int              `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
-	HostPortMax               *int              `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
-	SkipNatMapping            *bool             `mapstructure:"skip_nat_mapping" required:"false" cty:"skip_nat_mapping" hcl:"skip_nat_mapping"`
-	SSHHostPortMin            *int              `mapstructure:"ssh_host_port_min" required:"false" cty:"ssh_host_port_min" hcl:"ssh_host_port_min"`
-	SSHHostPortMax            *int              `mapstructure:"ssh_host_port_max" cty:"ssh_host_port_max" hcl:"ssh_host_port_max"`
-	FloppyFiles               []string          `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
-	FloppyDirectories         []string          `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
-	FloppyContent             map[string]string `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
-	FloppyLabel               *string           `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
-	CDFiles                   []string          `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
-	CDContent                 map[string]string `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
-	CDLabel                   *string           `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
-	ISOSkipCache              *bool             `mapstructure:"iso_skip_cache" required:"false" cty:"iso_skip_cache" hcl:"iso_skip_cache"`
-	Accelerator               *string           `mapstructure:"accelerator" required:"false" cty:"accelerator" hcl:"accelerator"`
-	AdditionalDiskSize        []string          `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
-	CpuCount                  *int              `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
-	Firmware                  *string           `mapstructure:"firmware" required:"false" cty:"firmware" hcl:"firmware"`
-	DiskInterface             *string           `mapstructure:"disk_interface" required:"false" cty:"disk_interface" hcl:"disk_interface"`
-	DiskSize                  *string           `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
-	SkipResizeDisk            *bool             `mapstructure:"skip_resize_disk" required:"false" cty:"skip_resize_disk" hcl:"skip_resize_disk"`
-	DiskCache                 *string           `mapstructure:"disk_cache" required:"false" cty:"disk_cache" hcl:"disk_cache"`
-	DiskDiscard               *string           `mapstructure:"disk_discard" required:"false" cty:"disk_discard" hcl:"disk_discard"`
-	DetectZeroes              *string           `mapstructure:"disk_detect_zeroes" required:"false" cty:"disk_detect_zeroes" hcl:"disk_detect_zeroes"`
-	SkipCompaction            *bool             `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
-	DiskCompression           *bool             `mapstructure:"disk_compression" required:"false" cty:"disk_compression" hcl:"disk_compression"`
-	Format                    *string           `mapstructure:"format" required:"false" cty:"format" hcl:"format"`
-	Headless                  *bool             `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
-	DiskImage                 *bool             `mapstructure:"disk_image" required:"false" cty:"disk_image" hcl:"disk_image"`
-	UseBackingFile            *bool             `mapstructure:"use_backing_file" required:"false" cty:"use_backing_file" hcl:"use_backing_file"`
-	MachineType               *string           `mapstructure:"machine_type" required:"false" cty:"machine_type" hcl:"machine_type"`
-	MemorySize                *int              `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
-	NetDevice                 *string           `mapstructure:"net_device" required:"false" cty:"net_device" hcl:"net_device"`
-	NetBridge                 *string           `mapstructure:"net_bridge" required:"false" cty:"net_bridge" hcl:"net_bridge"`
-	OutputDir                 *string           `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
-	QemuArgs                  [][]string        `mapstructure:"qemuargs" required:"false" cty:"qemuargs" hcl:"qemuargs"`
-	QemuImgArgs               *FlatQemuImgArgs  `mapstructure:"qemu_img_args" required:"false" cty:"qemu_img_args" hcl:"qemu_img_args"`
-	QemuBinary                *string           `mapstructure:"qemu_binary" required:"false" cty:"qemu_binary" hcl:"qemu_binary"`
-	QMPEnable                 *bool             `mapstructure:"qmp_enable" required:"false" cty:"qmp_enable" hcl:"qmp_enable"`
-	QMPSocketPath             *string           `mapstructure:"qmp_socket_path" required:"false" cty:"qmp_socket_path" hcl:"qmp_socket_path"`
-	UseDefaultDisplay         *bool             `mapstructure:"use_default_display" required:"false" cty:"use_default_display" hcl:"use_default_display"`
-	Display                   *string           `mapstructure:"display" required:"false" cty:"display" hcl:"display"`
-	VNCBindAddress            *string           `mapstructure:"vnc_bind_address" required:"false" cty:"vnc_bind_address" hcl:"vnc_bind_address"`
-	VNCUsePassword            *bool             `mapstructure:"vnc_use_password" required:"false" cty:"vnc_use_password" hcl:"vnc_use_password"`
-	VNCPortMin                *int              `mapstructure:"vnc_port_min" required:"false" cty:"vnc_port_min" hcl:"vnc_port_min"`
-	VNCPortMax                *int              `mapstructure:"vnc_port_max" cty:"vnc_port_max" hcl:"vnc_port_max"`
-	VMName                    *string           `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
-	CDROMInterface            *string           `mapstructure:"cdrom_interface" required:"false" cty:"cdrom_interface" hcl:"cdrom_interface"`
-	RunOnce                   *bool             `mapstructure:"run_once" cty:"run_once" hcl:"run_once"`
+	PackerBuildName           *string                      `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                      `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                      `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                        `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                        `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                      `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string            `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                     `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                   *string                      `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent               map[string]string            `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin               *int                         `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax               *int                         `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress               *string                      `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface             *string                      `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	ISOChecksum               *string                      `mapstructure:"iso_checksum" required:"true" cty:"iso_checksum" hcl:"iso_checksum"`
+	RawSingleISOUrl           *string                      `mapstructure:"iso_url" required:"true" cty:"iso_url" hcl:"iso_url"`
+	ISOUrls                   []string                     `mapstructure:"iso_urls" cty:"iso_urls" hcl:"iso_urls"`
+	TargetPath                *string                      `mapstructure:"iso_target_path" cty:"iso_target_path" hcl:"iso_target_path"`
+	TargetExtension           *string                      `mapstructure:"iso_target_extension" cty:"iso_target_extension" hcl:"iso_target_extension"`
+	BootGroupInterval         *string                      `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                  *string                      `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand               []string                     `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	DisableVNC                *bool                        `mapstructure:"disable_vnc" cty:"disable_vnc" hcl:"disable_vnc"`
+	BootKeyInterval           *string                      `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	ShutdownCommand           *string                      `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout           *string                      `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	Type                      *string                      `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                      `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                   *string                      `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                         `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                      `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                      `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                      `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                      `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                      `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                         `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                     `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                        `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                     `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                      `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                      `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                    *bool                        `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                      `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                      `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                        `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                        `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                         `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                      `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                         `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                        `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                      `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                      `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                        `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                      `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                      `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod     *string                      `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost              *string                      `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                         `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                      `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                      `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                      `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout       *string                      `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                     `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                     `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey              []byte                       `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                       `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                      `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                      `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                      `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                        `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                         `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                      `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                        `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                        `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                        `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	HostPortMin               *int                         `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
+	HostPortMax               *int                         `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
+	SkipNatMapping            *bool                        `mapstructure:"skip_nat_mapping" required:"false" cty:"skip_nat_mapping" hcl:"skip_nat_mapping"`
+	SSHHostPortMin            *int                         `mapstructure:"ssh_host_port_min" required:"false" cty:"ssh_host_port_min" hcl:"ssh_host_port_min"`
+	SSHHostPortMax            *int                         `mapstructure:"ssh_host_port_max" cty:"ssh_host_port_max" hcl:"ssh_host_port_max"`
+	FloppyFiles               []string                     `mapstructure:"floppy_files" cty:"floppy_files" hcl:"floppy_files"`
+	FloppyDirectories         []string                     `mapstructure:"floppy_dirs" cty:"floppy_dirs" hcl:"floppy_dirs"`
+	FloppyContent             map[string]string            `mapstructure:"floppy_content" cty:"floppy_content" hcl:"floppy_content"`
+	FloppyLabel               *string                      `mapstructure:"floppy_label" cty:"floppy_label" hcl:"floppy_label"`
+	CDFiles                   []string                     `mapstructure:"cd_files" cty:"cd_files" hcl:"cd_files"`
+	CDContent                 map[string]string            `mapstructure:"cd_content" cty:"cd_content" hcl:"cd_content"`
+	CDLabel                   *string                      `mapstructure:"cd_label" cty:"cd_label" hcl:"cd_label"`
+	ISOSkipCache              *bool                        `mapstructure:"iso_skip_cache" required:"false" cty:"iso_skip_cache" hcl:"iso_skip_cache"`
+	Accelerator               *string                      `mapstructure:"accelerator" required:"false" cty:"accelerator" hcl:"accelerator"`
+	AdditionalDiskSize        []string                     `mapstructure:"disk_additional_size" required:"false" cty:"disk_additional_size" hcl:"disk_additional_size"`
+	CpuCount                  *int                         `mapstructure:"cpus" required:"false" cty:"cpus" hcl:"cpus"`
+	Sockets                   *int                         `mapstructure:"sockets" required:"false" cty:"sockets" hcl:"sockets"`
+	Cores                     *int                         `mapstructure:"cores" required:"false" cty:"cores" hcl:"cores"`
+	Threads                   *int                         `mapstructure:"threads" required:"false" cty:"threads" hcl:"threads"`
+	CPUModel                  *string                      `mapstructure:"cpu_model" required:"false" cty:"cpu_model" hcl:"cpu_model"`
+	CPUFeatures               []string                     `mapstructure:"cpu_features" required:"false" cty:"cpu_features" hcl:"cpu_features"`
+	Firmware                  *string                      `mapstructure:"firmware" required:"false" cty:"firmware" hcl:"firmware"`
+	EFIFirmwareCode           *string                      `mapstructure:"efi_firmware_code" required:"false" cty:"efi_firmware_code" hcl:"efi_firmware_code"`
+	EFIFirmwareVars           *string                      `mapstructure:"efi_firmware_vars" required:"false" cty:"efi_firmware_vars" hcl:"efi_firmware_vars"`
+	SecureBoot                *bool                        `mapstructure:"secure_boot" required:"false" cty:"secure_boot" hcl:"secure_boot"`
+	SecureBootKeys            *FlatSecureBootKeysConfig    `mapstructure:"secure_boot_keys" required:"false" cty:"secure_boot_keys" hcl:"secure_boot_keys"`
+	KernelPath                *string                      `mapstructure:"kernel_path" required:"false" cty:"kernel_path" hcl:"kernel_path"`
+	KernelChecksum            *string                      `mapstructure:"kernel_checksum" required:"false" cty:"kernel_checksum" hcl:"kernel_checksum"`
+	InitrdPath                *string                      `mapstructure:"initrd_path" required:"false" cty:"initrd_path" hcl:"initrd_path"`
+	InitrdChecksum            *string                      `mapstructure:"initrd_checksum" required:"false" cty:"initrd_checksum" hcl:"initrd_checksum"`
+	KernelCmdline             *string                      `mapstructure:"kernel_cmdline" required:"false" cty:"kernel_cmdline" hcl:"kernel_cmdline"`
+	DiskInterface             *string                      `mapstructure:"disk_interface" required:"false" cty:"disk_interface" hcl:"disk_interface"`
+	DiskSize                  *string                      `mapstructure:"disk_size" required:"false" cty:"disk_size" hcl:"disk_size"`
+	SkipResizeDisk            *bool                        `mapstructure:"skip_resize_disk" required:"false" cty:"skip_resize_disk" hcl:"skip_resize_disk"`
+	DiskCache                 *string                      `mapstructure:"disk_cache" required:"false" cty:"disk_cache" hcl:"disk_cache"`
+	DiskDiscard               *string                      `mapstructure:"disk_discard" required:"false" cty:"disk_discard" hcl:"disk_discard"`
+	DetectZeroes              *string                      `mapstructure:"disk_detect_zeroes" required:"false" cty:"disk_detect_zeroes" hcl:"disk_detect_zeroes"`
+	SkipCompaction            *bool                        `mapstructure:"skip_compaction" required:"false" cty:"skip_compaction" hcl:"skip_compaction"`
+	DiskCompression           *bool                        `mapstructure:"disk_compression" required:"false" cty:"disk_compression" hcl:"disk_compression"`
+	Format                    *string                      `mapstructure:"format" required:"false" cty:"format" hcl:"format"`
+	Headless                  *bool                        `mapstructure:"headless" required:"false" cty:"headless" hcl:"headless"`
+	DiskImage                 *bool                        `mapstructure:"disk_image" required:"false" cty:"disk_image" hcl:"disk_image"`
+	UseBackingFile            *bool                        `mapstructure:"use_backing_file" required:"false" cty:"use_backing_file" hcl:"use_backing_file"`
+	MachineType               *string                      `mapstructure:"machine_type" required:"false" cty:"machine_type" hcl:"machine_type"`
+	MemorySize                *string                      `mapstructure:"memory" required:"false" cty:"memory" hcl:"memory"`
+	MaxMemory                 *string                      `mapstructure:"max_memory" required:"false" cty:"max_memory" hcl:"max_memory"`
+	MemorySlots               *int                         `mapstructure:"memory_slots" required:"false" cty:"memory_slots" hcl:"memory_slots"`
+	MemoryBalloon             *bool                        `mapstructure:"memory_balloon" required:"false" cty:"memory_balloon" hcl:"memory_balloon"`
+	MemoryBackend             *string                      `mapstructure:"memory_backend" required:"false" cty:"memory_backend" hcl:"memory_backend"`
+	MemoryBackendPath         *string                      `mapstructure:"memory_backend_path" required:"false" cty:"memory_backend_path" hcl:"memory_backend_path"`
+	MemoryHugepages           *bool                        `mapstructure:"memory_hugepages" required:"false" cty:"memory_hugepages" hcl:"memory_hugepages"`
+	MemoryShare               *bool                        `mapstructure:"memory_share" required:"false" cty:"memory_share" hcl:"memory_share"`
+	MemoryPrealloc            *bool                        `mapstructure:"memory_prealloc" required:"false" cty:"memory_prealloc" hcl:"memory_prealloc"`
+	NUMANodes                 []FlatNUMANodeConfig         `mapstructure:"numa_node" required:"false" cty:"numa_node" hcl:"numa_node"`
+	NetDevice                 *string                      `mapstructure:"net_device" required:"false" cty:"net_device" hcl:"net_device"`
+	NetBridge                 *string                      `mapstructure:"net_bridge" required:"false" cty:"net_bridge" hcl:"net_bridge"`
+	NetIPv6                   *bool                        `mapstructure:"net_ipv6" required:"false" cty:"net_ipv6" hcl:"net_ipv6"`
+	MACAddress                *string                      `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
+	NetworkInterfaces         []FlatNetworkInterfaceConfig `mapstructure:"network_interface" required:"false" cty:"network_interface" hcl:"network_interface"`
+	PortForwards              []FlatPortForwardConfig      `mapstructure:"port_forward" required:"false" cty:"port_forward" hcl:"port_forward"`
+	NetworkMode               *string                      `mapstructure:"network_mode" required:"false" cty:"network_mode" hcl:"network_mode"`
+	GuestForwards             []FlatGuestForwardConfig     `mapstructure:"guest_forward" required:"false" cty:"guest_forward" hcl:"guest_forward"`
+	NetworkAllowlist          []string                     `mapstructure:"network_allowlist" required:"false" cty:"network_allowlist" hcl:"network_allowlist"`
+	GuestAddressSources       []string                     `mapstructure:"guest_address_sources" required:"false" cty:"guest_address_sources" hcl:"guest_address_sources"`
+	DnsmasqLeaseFile          *string                      `mapstructure:"dnsmasq_lease_file" required:"false" cty:"dnsmasq_lease_file" hcl:"dnsmasq_lease_file"`
+	GuestAddress              *string                      `mapstructure:"guest_address" required:"false" cty:"guest_address" hcl:"guest_address"`
+	GuestAddressPollInterval  *string                      `mapstructure:"guest_address_poll_interval" required:"false" cty:"guest_address_poll_interval" hcl:"guest_address_poll_interval"`
+	OutputDir                 *string                      `mapstructure:"output_directory" required:"false" cty:"output_directory" hcl:"output_directory"`
+	QemuArgs                  [][]string                   `mapstructure:"qemuargs" required:"false" cty:"qemuargs" hcl:"qemuargs"`
+	QemuImgArgs               *FlatQemuImgArgs             `mapstructure:"qemu_img_args" required:"false" cty:"qemu_img_args" hcl:"qemu_img_args"`
+	QemuBinary                *string                      `mapstructure:"qemu_binary" required:"false" cty:"qemu_binary" hcl:"qemu_binary"`
+	QMPEnable                 *bool                        `mapstructure:"qmp_enable" required:"false" cty:"qmp_enable" hcl:"qmp_enable"`
+	QMPSocketPath             *string                      `mapstructure:"qmp_socket_path" required:"false" cty:"qmp_socket_path" hcl:"qmp_socket_path"`
+	UseDefaultDisplay         *bool                        `mapstructure:"use_default_display" required:"false" cty:"use_default_display" hcl:"use_default_display"`
+	VGA                       *string                      `mapstructure:"vga" required:"false" cty:"vga" hcl:"vga"`
+	VideoDevice               *string                      `mapstructure:"video_device" required:"false" cty:"video_device" hcl:"video_device"`
+	Display                   *string                      `mapstructure:"display" required:"false" cty:"display" hcl:"display"`
+	VNCBindAddress            *string                      `mapstructure:"vnc_bind_address" required:"false" cty:"vnc_bind_address" hcl:"vnc_bind_address"`
+	VNCUsePassword            *bool                        `mapstructure:"vnc_use_password" required:"false" cty:"vnc_use_password" hcl:"vnc_use_password"`
+	VNCPortMin                *int                         `mapstructure:"vnc_port_min" required:"false" cty:"vnc_port_min" hcl:"vnc_port_min"`
+	VNCPortMax                *int                         `mapstructure:"vnc_port_max" cty:"vnc_port_max" hcl:"vnc_port_max"`
+	VNCSocket                 *bool                        `mapstructure:"vnc_socket" required:"false" cty:"vnc_socket" hcl:"vnc_socket"`
+	VNCSocketPath             *string                      `mapstructure:"vnc_socket_path" required:"false" cty:"vnc_socket_path" hcl:"vnc_socket_path"`
+	DisplayProtocol           *string                      `mapstructure:"display_protocol" required:"false" cty:"display_protocol" hcl:"display_protocol"`
+	SPICEBindAddress          *string                      `mapstructure:"spice_bind_address" required:"false" cty:"spice_bind_address" hcl:"spice_bind_address"`
+	SPICEUsePassword          *bool                        `mapstructure:"spice_use_password" required:"false" cty:"spice_use_password" hcl:"spice_use_password"`
+	SPICEPortMin              *int                         `mapstructure:"spice_port_min" required:"false" cty:"spice_port_min" hcl:"spice_port_min"`
+	SPICEPortMax              *int                         `mapstructure:"spice_port_max" cty:"spice_port_max" hcl:"spice_port_max"`
+	VMName                    *string                      `mapstructure:"vm_name" required:"false" cty:"vm_name" hcl:"vm_name"`
+	CDROMInterface            *string                      `mapstructure:"cdrom_interface" required:"false" cty:"cdrom_interface" hcl:"cdrom_interface"`
+	USBController             *string                      `mapstructure:"usb_controller" required:"false" cty:"usb_controller" hcl:"usb_controller"`
+	InputDevices              []string                     `mapstructure:"input_devices" required:"false" cty:"input_devices" hcl:"input_devices"`
+	AudioDevice               *string                      `mapstructure:"audio_device" required:"false" cty:"audio_device" hcl:"audio_device"`
+	RNG                       *bool                        `mapstructure:"rng" required:"false" cty:"rng" hcl:"rng"`
+	RNGMaxBytes               *int                         `mapstructure:"rng_max_bytes" required:"false" cty:"rng_max_bytes" hcl:"rng_max_bytes"`
+	RNGPeriod                 *string                      `mapstructure:"rng_period" required:"false" cty:"rng_period" hcl:"rng_period"`
+	CloudInit                 *FlatCloudInitConfig         `mapstructure:"cloud_init" required:"false" cty:"cloud_init" hcl:"cloud_init"`
+	Ignition                  *FlatIgnitionConfig          `mapstructure:"ignition_config" required:"false" cty:"ignition_config" hcl:"ignition_config"`
+	FwCfg                     []FlatFwCfgConfig            `mapstructure:"fw_cfg" required:"false" cty:"fw_cfg" hcl:"fw_cfg"`
+	SMBIOS                    []FlatSMBIOSConfig           `mapstructure:"smbios" required:"false" cty:"smbios" hcl:"smbios"`
+	SharedFolders             []FlatSharedFolderConfig     `mapstructure:"shared_folder" required:"false" cty:"shared_folder" hcl:"shared_folder"`
+	RunOnce                   *bool                        `mapstructure:"run_once" cty:"run_once" hcl:"run_once"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -238,7 +319,21 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"accelerator":                  &hcldec.AttrSpec{Name: "accelerator", Type: cty.String, Required: false},
 		"disk_additional_size":         &hcldec.AttrSpec{Name: "disk_additional_size", Type: cty.List(cty.String), Required: false},
 		"cpus":                         &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
+		"sockets":                      &hcldec.AttrSpec{Name: "sockets", Type: cty.Number, Required: false},
+		"cores":                        &hcldec.AttrSpec{Name: "cores", Type: cty.Number, Required: false},
+		"threads":                      &hcldec.AttrSpec{Name: "threads", Type: cty.Number, Required: false},
+		"cpu_model":                    &hcldec.AttrSpec{Name: "cpu_model", Type: cty.String, Required: false},
+		"cpu_features":                 &hcldec.AttrSpec{Name: "cpu_features", Type: cty.List(cty.String), Required: false},
 		"firmware":                     &hcldec.AttrSpec{Name: "firmware", Type: cty.String, Required: false},
+		"efi_firmware_code":            &hcldec.AttrSpec{Name: "efi_firmware_code", Type: cty.String, Required: false},
+		"efi_firmware_vars":            &hcldec.AttrSpec{Name: "efi_firmware_vars", Type: cty.String, Required: false},
+		"secure_boot":                  &hcldec.AttrSpec{Name: "secure_boot", Type: cty.Bool, Required: false},
+		"secure_boot_keys":             &hcldec.BlockSpec{TypeName: "secure_boot_keys", Nested: hcldec.ObjectSpec((*FlatSecureBootKeysConfig)(nil).HCL2Spec())},
+		"kernel_path":                  &hcldec.AttrSpec{Name: "kernel_path", Type: cty.String, Required: false},
+		"kernel_checksum":              &hcldec.AttrSpec{Name: "kernel_checksum", Type: cty.String, Required: false},
+		"initrd_path":                  &hcldec.AttrSpec{Name: "initrd_path", Type: cty.String, Required: false},
+		"initrd_checksum":              &hcldec.AttrSpec{Name: "initrd_checksum", Type: cty.String, Required: false},
+		"kernel_cmdline":               &hcldec.AttrSpec{Name: "kernel_cmdline", Type: cty.String, Required: false},
 		"disk_interface":               &hcldec.AttrSpec{Name: "disk_interface", Type: cty.String, Required: false},
 		"disk_size":                    &hcldec.AttrSpec{Name: "disk_size", Type: cty.String, Required: false},
 		"skip_resize_disk":             &hcldec.AttrSpec{Name: "skip_resize_disk", Type: cty.Bool, Required: false},
@@ -252,9 +347,29 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"disk_image":                   &hcldec.AttrSpec{Name: "disk_image", Type: cty.Bool, Required: false},
 		"use_backing_file":             &hcldec.AttrSpec{Name: "use_backing_file", Type: cty.Bool, Required: false},
 		"machine_type":                 &hcldec.AttrSpec{Name: "machine_type", Type: cty.String, Required: false},
-		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"memory":                       &hcldec.AttrSpec{Name: "memory", Type: cty.String, Required: false},
+		"max_memory":                   &hcldec.AttrSpec{Name: "max_memory", Type: cty.String, Required: false},
+		"memory_slots":                 &hcldec.AttrSpec{Name: "memory_slots", Type: cty.Number, Required: false},
+		"memory_balloon":               &hcldec.AttrSpec{Name: "memory_balloon", Type: cty.Bool, Required: false},
+		"memory_backend":               &hcldec.AttrSpec{Name: "memory_backend", Type: cty.String, Required: false},
+		"memory_backend_path":          &hcldec.AttrSpec{Name: "memory_backend_path", Type: cty.String, Required: false},
+		"memory_hugepages":             &hcldec.AttrSpec{Name: "memory_hugepages", Type: cty.Bool, Required: false},
+		"memory_share":                 &hcldec.AttrSpec{Name: "memory_share", Type: cty.Bool, Required: false},
+		"memory_prealloc":              &hcldec.AttrSpec{Name: "memory_prealloc", Type: cty.Bool, Required: false},
+		"numa_node":                    &hcldec.BlockListSpec{TypeName: "numa_node", Nested: hcldec.ObjectSpec((*FlatNUMANodeConfig)(nil).HCL2Spec())},
 		"net_device":                   &hcldec.AttrSpec{Name: "net_device", Type: cty.String, Required: false},
 		"net_bridge":                   &hcldec.AttrSpec{Name: "net_bridge", Type: cty.String, Required: false},
+		"net_ipv6":                     &hcldec.AttrSpec{Name: "net_ipv6", Type: cty.Bool, Required: false},
+		"mac_address":                  &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
+		"network_interface":            &hcldec.BlockListSpec{TypeName: "network_interface", Nested: hcldec.ObjectSpec((*FlatNetworkInterfaceConfig)(nil).HCL2Spec())},
+		"port_forward":                 &hcldec.BlockListSpec{TypeName: "port_forward", Nested: hcldec.ObjectSpec((*FlatPortForwardConfig)(nil).HCL2Spec())},
+		"network_mode":                 &hcldec.AttrSpec{Name: "network_mode", Type: cty.String, Required: false},
+		"guest_forward":                &hcldec.BlockListSpec{TypeName: "guest_forward", Nested: hcldec.ObjectSpec((*FlatGuestForwardConfig)(nil).HCL2Spec())},
+		"network_allowlist":            &hcldec.AttrSpec{Name: "network_allowlist", Type: cty.List(cty.String), Required: false},
+		"guest_address_sources":        &hcldec.AttrSpec{Name: "guest_address_sources", Type: cty.List(cty.String), Required: false},
+		"dnsmasq_lease_file":           &hcldec.AttrSpec{Name: "dnsmasq_lease_file", Type: cty.String, Required: false},
+		"guest_address":                &hcldec.AttrSpec{Name: "guest_address", Type: cty.String, Required: false},
+		"guest_address_poll_interval":  &hcldec.AttrSpec{Name: "guest_address_poll_interval", Type: cty.String, Required: false},
 		"output_directory":             &hcldec.AttrSpec{Name: "output_directory", Type: cty.String, Required: false},
 		"qemuargs":                     &hcldec.AttrSpec{Name: "qemuargs", Type: cty.List(cty.List(cty.String)), Required: false},
 		"qemu_img_args":                &hcldec.BlockSpec{TypeName: "qemu_img_args", Nested: hcldec.ObjectSpec((*FlatQemuImgArgs)(nil).HCL2Spec())},
@@ -262,18 +377,220 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"qmp_enable":                   &hcldec.AttrSpec{Name: "qmp_enable", Type: cty.Bool, Required: false},
 		"qmp_socket_path":              &hcldec.AttrSpec{Name: "qmp_socket_path", Type: cty.String, Required: false},
 		"use_default_display":          &hcldec.AttrSpec{Name: "use_default_display", Type: cty.Bool, Required: false},
+		"vga":                          &hcldec.AttrSpec{Name: "vga", Type: cty.String, Required: false},
+		"video_device":                 &hcldec.AttrSpec{Name: "video_device", Type: cty.String, Required: false},
 		"display":                      &hcldec.AttrSpec{Name: "display", Type: cty.String, Required: false},
 		"vnc_bind_address":             &hcldec.AttrSpec{Name: "vnc_bind_address", Type: cty.String, Required: false},
 		"vnc_use_password":             &hcldec.AttrSpec{Name: "vnc_use_password", Type: cty.Bool, Required: false},
 		"vnc_port_min":                 &hcldec.AttrSpec{Name: "vnc_port_min", Type: cty.Number, Required: false},
 		"vnc_port_max":                 &hcldec.AttrSpec{Name: "vnc_port_max", Type: cty.Number, Required: false},
+		"vnc_socket":                   &hcldec.AttrSpec{Name: "vnc_socket", Type: cty.Bool, Required: false},
+		"vnc_socket_path":              &hcldec.AttrSpec{Name: "vnc_socket_path", Type: cty.String, Required: false},
+		"display_protocol":             &hcldec.AttrSpec{Name: "display_protocol", Type: cty.String, Required: false},
+		"spice_bind_address":           &hcldec.AttrSpec{Name: "spice_bind_address", Type: cty.String, Required: false},
+		"spice_use_password":           &hcldec.AttrSpec{Name: "spice_use_password", Type: cty.Bool, Required: false},
+		"spice_port_min":               &hcldec.AttrSpec{Name: "spice_port_min", Type: cty.Number, Required: false},
+		"spice_port_max":               &hcldec.AttrSpec{Name: "spice_port_max", Type: cty.Number, Required: false},
 		"vm_name":                      &hcldec.AttrSpec{Name: "vm_name", Type: cty.String, Required: false},
 		"cdrom_interface":              &hcldec.AttrSpec{Name: "cdrom_interface", Type: cty.String, Required: false},
+		"usb_controller":               &hcldec.AttrSpec{Name: "usb_controller", Type: cty.String, Required: false},
+		"input_devices":                &hcldec.AttrSpec{Name: "input_devices", Type: cty.List(cty.String), Required: false},
+		"audio_device":                 &hcldec.AttrSpec{Name: "audio_device", Type: cty.String, Required: false},
+		"rng":                          &hcldec.AttrSpec{Name: "rng", Type: cty.Bool, Required: false},
+		"rng_max_bytes":                &hcldec.AttrSpec{Name: "rng_max_bytes", Type: cty.Number, Required: false},
+		"rng_period":                   &hcldec.AttrSpec{Name: "rng_period", Type: cty.String, Required: false},
+		"cloud_init":                   &hcldec.BlockSpec{TypeName: "cloud_init", Nested: hcldec.ObjectSpec((*FlatCloudInitConfig)(nil).HCL2Spec())},
+		"ignition_config":              &hcldec.BlockSpec{TypeName: "ignition_config", Nested: hcldec.ObjectSpec((*FlatIgnitionConfig)(nil).HCL2Spec())},
+		"fw_cfg":                       &hcldec.BlockListSpec{TypeName: "fw_cfg", Nested: hcldec.ObjectSpec((*FlatFwCfgConfig)(nil).HCL2Spec())},
+		"smbios":                       &hcldec.BlockListSpec{TypeName: "smbios", Nested: hcldec.ObjectSpec((*FlatSMBIOSConfig)(nil).HCL2Spec())},
+		"shared_folder":                &hcldec.BlockListSpec{TypeName: "shared_folder", Nested: hcldec.ObjectSpec((*FlatSharedFolderConfig)(nil).HCL2Spec())},
 		"run_once":                     &hcldec.AttrSpec{Name: "run_once", Type: cty.Bool, Required: false},
 	}
 	return s
 }
 
+// FlatFwCfgConfig is an auto-generated flat version of FwCfgConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatFwCfgConfig struct {
+	Name   *string `mapstructure:"name" required:"true" cty:"name" hcl:"name"`
+	File   *string `mapstructure:"file" required:"false" cty:"file" hcl:"file"`
+	String *string `mapstructure:"string" required:"false" cty:"string" hcl:"string"`
+}
+
+// FlatMapstructure returns a new FlatFwCfgConfig.
+// FlatFwCfgConfig is an auto-generated flat version of FwCfgConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*FwCfgConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatFwCfgConfig)
+}
+
+// HCL2Spec returns the hcl spec of a FwCfgConfig.
+// This spec is used by HCL to read the fields of FwCfgConfig.
+// The decoded values from this spec will then be applied to a FlatFwCfgConfig.
+func (*FlatFwCfgConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":   &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"file":   &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
+		"string": &hcldec.AttrSpec{Name: "string", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatGuestForwardConfig is an auto-generated flat version of GuestForwardConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatGuestForwardConfig struct {
+	Name         *string `mapstructure:"name" required:"true" cty:"name" hcl:"name"`
+	GuestAddress *string `mapstructure:"guest_address" required:"true" cty:"guest_address" hcl:"guest_address"`
+	Target       *string `mapstructure:"target" required:"false" cty:"target" hcl:"target"`
+	Command      *string `mapstructure:"command" required:"false" cty:"command" hcl:"command"`
+}
+
+// FlatMapstructure returns a new FlatGuestForwardConfig.
+// FlatGuestForwardConfig is an auto-generated flat version of GuestForwardConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*GuestForwardConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatGuestForwardConfig)
+}
+
+// HCL2Spec returns the hcl spec of a GuestForwardConfig.
+// This spec is used by HCL to read the fields of GuestForwardConfig.
+// The decoded values from this spec will then be applied to a FlatGuestForwardConfig.
+func (*FlatGuestForwardConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":          &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"guest_address": &hcldec.AttrSpec{Name: "guest_address", Type: cty.String, Required: false},
+		"target":        &hcldec.AttrSpec{Name: "target", Type: cty.String, Required: false},
+		"command":       &hcldec.AttrSpec{Name: "command", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatIgnitionConfig is an auto-generated flat version of IgnitionConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatIgnitionConfig struct {
+	File    *string `mapstructure:"file" required:"false" cty:"file" hcl:"file"`
+	Content *string `mapstructure:"content" required:"false" cty:"content" hcl:"content"`
+	Butane  *bool   `mapstructure:"butane" required:"false" cty:"butane" hcl:"butane"`
+}
+
+// FlatMapstructure returns a new FlatIgnitionConfig.
+// FlatIgnitionConfig is an auto-generated flat version of IgnitionConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*IgnitionConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatIgnitionConfig)
+}
+
+// HCL2Spec returns the hcl spec of a IgnitionConfig.
+// This spec is used by HCL to read the fields of IgnitionConfig.
+// The decoded values from this spec will then be applied to a FlatIgnitionConfig.
+func (*FlatIgnitionConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"file":    &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
+		"content": &hcldec.AttrSpec{Name: "content", Type: cty.String, Required: false},
+		"butane":  &hcldec.AttrSpec{Name: "butane", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatNUMANodeConfig is an auto-generated flat version of NUMANodeConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatNUMANodeConfig struct {
+	CPUs      *string `mapstructure:"cpus" required:"true" cty:"cpus" hcl:"cpus"`
+	Memory    *string `mapstructure:"memory" required:"true" cty:"memory" hcl:"memory"`
+	HostNodes *string `mapstructure:"host_nodes" required:"false" cty:"host_nodes" hcl:"host_nodes"`
+}
+
+// FlatMapstructure returns a new FlatNUMANodeConfig.
+// FlatNUMANodeConfig is an auto-generated flat version of NUMANodeConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*NUMANodeConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatNUMANodeConfig)
+}
+
+// HCL2Spec returns the hcl spec of a NUMANodeConfig.
+// This spec is used by HCL to read the fields of NUMANodeConfig.
+// The decoded values from this spec will then be applied to a FlatNUMANodeConfig.
+func (*FlatNUMANodeConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"cpus":       &hcldec.AttrSpec{Name: "cpus", Type: cty.String, Required: false},
+		"memory":     &hcldec.AttrSpec{Name: "memory", Type: cty.String, Required: false},
+		"host_nodes": &hcldec.AttrSpec{Name: "host_nodes", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatNetworkInterfaceConfig is an auto-generated flat version of NetworkInterfaceConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatNetworkInterfaceConfig struct {
+	Backend       *string `mapstructure:"backend" required:"false" cty:"backend" hcl:"backend"`
+	Model         *string `mapstructure:"model" required:"false" cty:"model" hcl:"model"`
+	MACAddress    *string `mapstructure:"mac_address" required:"false" cty:"mac_address" hcl:"mac_address"`
+	Communicator  *bool   `mapstructure:"communicator" required:"false" cty:"communicator" hcl:"communicator"`
+	Bridge        *string `mapstructure:"bridge" required:"false" cty:"bridge" hcl:"bridge"`
+	Ifname        *string `mapstructure:"ifname" required:"false" cty:"ifname" hcl:"ifname"`
+	SocketMode    *string `mapstructure:"socket_mode" required:"false" cty:"socket_mode" hcl:"socket_mode"`
+	SocketAddress *string `mapstructure:"socket_address" required:"false" cty:"socket_address" hcl:"socket_address"`
+	VDESocket     *string `mapstructure:"vde_socket" required:"false" cty:"vde_socket" hcl:"vde_socket"`
+}
+
+// FlatMapstructure returns a new FlatNetworkInterfaceConfig.
+// FlatNetworkInterfaceConfig is an auto-generated flat version of NetworkInterfaceConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*NetworkInterfaceConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatNetworkInterfaceConfig)
+}
+
+// HCL2Spec returns the hcl spec of a NetworkInterfaceConfig.
+// This spec is used by HCL to read the fields of NetworkInterfaceConfig.
+// The decoded values from this spec will then be applied to a FlatNetworkInterfaceConfig.
+func (*FlatNetworkInterfaceConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"backend":        &hcldec.AttrSpec{Name: "backend", Type: cty.String, Required: false},
+		"model":          &hcldec.AttrSpec{Name: "model", Type: cty.String, Required: false},
+		"mac_address":    &hcldec.AttrSpec{Name: "mac_address", Type: cty.String, Required: false},
+		"communicator":   &hcldec.AttrSpec{Name: "communicator", Type: cty.Bool, Required: false},
+		"bridge":         &hcldec.AttrSpec{Name: "bridge", Type: cty.String, Required: false},
+		"ifname":         &hcldec.AttrSpec{Name: "ifname", Type: cty.String, Required: false},
+		"socket_mode":    &hcldec.AttrSpec{Name: "socket_mode", Type: cty.String, Required: false},
+		"socket_address": &hcldec.AttrSpec{Name: "socket_address", Type: cty.String, Required: false},
+		"vde_socket":     &hcldec.AttrSpec{Name: "vde_socket", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatPortForwardConfig is an auto-generated flat version of PortForwardConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatPortForwardConfig struct {
+	Name        *string `mapstructure:"name" required:"true" cty:"name" hcl:"name"`
+	GuestPort   *int    `mapstructure:"guest_port" required:"true" cty:"guest_port" hcl:"guest_port"`
+	Protocol    *string `mapstructure:"protocol" required:"false" cty:"protocol" hcl:"protocol"`
+	HostPort    *int    `mapstructure:"host_port" required:"false" cty:"host_port" hcl:"host_port"`
+	HostPortMin *int    `mapstructure:"host_port_min" required:"false" cty:"host_port_min" hcl:"host_port_min"`
+	HostPortMax *int    `mapstructure:"host_port_max" required:"false" cty:"host_port_max" hcl:"host_port_max"`
+}
+
+// FlatMapstructure returns a new FlatPortForwardConfig.
+// FlatPortForwardConfig is an auto-generated flat version of PortForwardConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*PortForwardConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatPortForwardConfig)
+}
+
+// HCL2Spec returns the hcl spec of a PortForwardConfig.
+// This spec is used by HCL to read the fields of PortForwardConfig.
+// The decoded values from this spec will then be applied to a FlatPortForwardConfig.
+func (*FlatPortForwardConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":          &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"guest_port":    &hcldec.AttrSpec{Name: "guest_port", Type: cty.Number, Required: false},
+		"protocol":      &hcldec.AttrSpec{Name: "protocol", Type: cty.String, Required: false},
+		"host_port":     &hcldec.AttrSpec{Name: "host_port", Type: cty.Number, Required: false},
+		"host_port_min": &hcldec.AttrSpec{Name: "host_port_min", Type: cty.Number, Required: false},
+		"host_port_max": &hcldec.AttrSpec{Name: "host_port_max", Type: cty.Number, Required: false},
+	}
+	return s
+}
+
 // FlatQemuImgArgs is an auto-generated flat version of QemuImgArgs.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatQemuImgArgs struct {
@@ -300,3 +617,92 @@ func (*FlatQemuImgArgs) HCL2Spec() map[string]hcldec.Spec {
 	}
 	return s
 }
+
+// FlatSMBIOSConfig is an auto-generated flat version of SMBIOSConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSMBIOSConfig struct {
+	Type   *int              `mapstructure:"type" required:"true" cty:"type" hcl:"type"`
+	Fields map[string]string `mapstructure:"fields" required:"false" cty:"fields" hcl:"fields"`
+	Values []string          `mapstructure:"values" required:"false" cty:"values" hcl:"values"`
+}
+
+// FlatMapstructure returns a new FlatSMBIOSConfig.
+// FlatSMBIOSConfig is an auto-generated flat version of SMBIOSConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SMBIOSConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSMBIOSConfig)
+}
+
+// HCL2Spec returns the hcl spec of a SMBIOSConfig.
+// This spec is used by HCL to read the fields of SMBIOSConfig.
+// The decoded values from this spec will then be applied to a FlatSMBIOSConfig.
+func (*FlatSMBIOSConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"type":   &hcldec.AttrSpec{Name: "type", Type: cty.Number, Required: false},
+		"fields": &hcldec.AttrSpec{Name: "fields", Type: cty.Map(cty.String), Required: false},
+		"values": &hcldec.AttrSpec{Name: "values", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatSecureBootKeysConfig is an auto-generated flat version of SecureBootKeysConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSecureBootKeysConfig struct {
+	PK        *string  `mapstructure:"pk" required:"true" cty:"pk" hcl:"pk"`
+	KEK       []string `mapstructure:"kek" required:"false" cty:"kek" hcl:"kek"`
+	DB        []string `mapstructure:"db" required:"false" cty:"db" hcl:"db"`
+	OwnerGUID *string  `mapstructure:"owner_guid" required:"false" cty:"owner_guid" hcl:"owner_guid"`
+}
+
+// FlatMapstructure returns a new FlatSecureBootKeysConfig.
+// FlatSecureBootKeysConfig is an auto-generated flat version of SecureBootKeysConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SecureBootKeysConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSecureBootKeysConfig)
+}
+
+// HCL2Spec returns the hcl spec of a SecureBootKeysConfig.
+// This spec is used by HCL to read the fields of SecureBootKeysConfig.
+// The decoded values from this spec will then be applied to a FlatSecureBootKeysConfig.
+func (*FlatSecureBootKeysConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"pk":         &hcldec.AttrSpec{Name: "pk", Type: cty.String, Required: false},
+		"kek":        &hcldec.AttrSpec{Name: "kek", Type: cty.List(cty.String), Required: false},
+		"db":         &hcldec.AttrSpec{Name: "db", Type: cty.List(cty.String), Required: false},
+		"owner_guid": &hcldec.AttrSpec{Name: "owner_guid", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatSharedFolderConfig is an auto-generated flat version of SharedFolderConfig.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatSharedFolderConfig struct {
+	Name          *string `mapstructure:"name" required:"true" cty:"name" hcl:"name"`
+	HostPath      *string `mapstructure:"host_path" required:"true" cty:"host_path" hcl:"host_path"`
+	Tag           *string `mapstructure:"tag" required:"false" cty:"tag" hcl:"tag"`
+	Type          *string `mapstructure:"type" required:"false" cty:"type" hcl:"type"`
+	ReadOnly      *bool   `mapstructure:"read_only" required:"false" cty:"read_only" hcl:"read_only"`
+	SecurityModel *string `mapstructure:"security_model" required:"false" cty:"security_model" hcl:"security_model"`
+}
+
+// FlatMapstructure returns a new FlatSharedFolderConfig.
+// FlatSharedFolderConfig is an auto-generated flat version of SharedFolderConfig.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*SharedFolderConfig) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatSharedFolderConfig)
+}
+
+// HCL2Spec returns the hcl spec of a SharedFolderConfig.
+// This spec is used by HCL to read the fields of SharedFolderConfig.
+// The decoded values from this spec will then be applied to a FlatSharedFolderConfig.
+func (*FlatSharedFolderConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":           &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"host_path":      &hcldec.AttrSpec{Name: "host_path", Type: cty.String, Required: false},
+		"tag":            &hcldec.AttrSpec{Name: "tag", Type: cty.String, Required: false},
+		"type":           &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+		"read_only":      &hcldec.AttrSpec{Name: "read_only", Type: cty.Bool, Required: false},
+		"security_model": &hcldec.AttrSpec{Name: "security_model", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -53,6 +54,61 @@ func testConfig() map[string]interface{} {
 	}
 }
 
+// prepareTestCase is a configuration merged into testConfig, and whether
+// preparing it should fail.
+type prepareTestCase struct {
+	Config      map[string]interface{}
+	ErrExpected bool
+}
+
+// testPrepare prepares testConfig merged with raw.
+func testPrepare(raw map[string]interface{}) (*Config, []string, error) {
+	config := testConfig()
+	for k, v := range raw {
+		config[k] = v
+	}
+	var c Config
+	warns, err := c.Prepare(config)
+	return &c, warns, err
+}
+
+// testPrepareConfig prepares testConfig merged with raw, which must be valid.
+func testPrepareConfig(t *testing.T, raw map[string]interface{}) *Config {
+	t.Helper()
+	c, _, err := testPrepare(raw)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	return c
+}
+
+// testPrepareCases checks whether preparing each test case fails.
+func testPrepareCases(t *testing.T, testCases []prepareTestCase) {
+	t.Helper()
+	for _, tc := range testCases {
+		_, _, err := testPrepare(tc.Config)
+		if (err != nil) != tc.ErrExpected {
+			t.Errorf("bad: %#v; err expected: %t; err received: %v", tc.Config, tc.ErrExpected, err)
+		}
+	}
+}
+
+// testPrepareBuilder prepares a builder with testConfig merged with raw,
+// which must be valid, and returns its generated data.
+func testPrepareBuilder(t *testing.T, raw map[string]interface{}) (*Builder, []string) {
+	t.Helper()
+	config := testConfig()
+	for k, v := range raw {
+		config[k] = v
+	}
+	var b Builder
+	generatedData, _, err := b.Prepare(config)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	return &b, generatedData
+}
+
 func TestBuilderPrepare_Defaults(t *testing.T) {
 	var c Config
 	config := testConfig()
@@ -311,6 +367,723 @@ func TestBuilderPrepare_SkipResizeDisk(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_KernelPath(t *testing.T) {
+	var c Config
+	config := testConfig()
+
+	// Bad: kernel_cmdline without kernel_path
+	config["kernel_cmdline"] = "console=ttyS0"
+	_, err := c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad: kernel_path without checksum
+	config["kernel_path"] = "http://example.com/vmlinuz"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Bad: initrd_path without checksum
+	config["kernel_checksum"] = "none"
+	config["initrd_path"] = "http://example.com/initrd.gz"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	config["initrd_checksum"] = "none"
+	c = Config{}
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if c.KernelCmdline != "console=ttyS0" {
+		t.Fatalf("bad kernel_cmdline: %s", c.KernelCmdline)
+	}
+}
+
+func TestBuilderPrepare_CloudInit(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["cloud_init"] = map[string]interface{}{
+		"network_config": "version: 2",
+	}
+
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CloudInit == nil || c.CloudInit.NetworkConfig != "version: 2" {
+		t.Fatalf("bad cloud_init: %#v", c.CloudInit)
+	}
+
+	// Bad: no user_data to create a WinRM user
+	config["communicator"] = "winrm"
+	config["winrm_username"] = "packer"
+	c = Config{}
+	_, err = c.Prepare(config)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_CloudInitDiskImage(t *testing.T) {
+	var c Config
+	config := testConfig()
+	config["disk_image"] = true
+
+	warns, err := c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CloudInit == nil || !c.CloudInit.implied {
+		t.Fatal("cloud_init should be implied when no SSH credentials are set")
+	}
+
+	config["ssh_password"] = "packer"
+	c = Config{}
+	warns, err = c.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if c.CloudInit != nil {
+		t.Fatalf("cloud_init should not be enabled when ssh_password is set: %#v", c.CloudInit)
+	}
+}
+
+func TestBuilderPrepare_Ignition(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"ignition_config": map[string]interface{}{"content": `{"ignition": {"version": "3.3.0"}}`}}, false},
+		{map[string]interface{}{"ignition_config": map[string]interface{}{"file": "testdata/floppies/bar.bat", "butane": true}}, false},
+		{map[string]interface{}{"ignition_config": map[string]interface{}{}}, true},
+		{map[string]interface{}{"ignition_config": map[string]interface{}{"content": "{}", "file": "testdata/floppies/bar.bat"}}, true},
+		{map[string]interface{}{"ignition_config": map[string]interface{}{"file": "testdata/missing"}}, true},
+		// Both cloud-init and Ignition
+		{map[string]interface{}{"ignition_config": map[string]interface{}{"content": "{}"}, "cloud_init": map[string]interface{}{}}, true},
+	})
+
+	// Ignition configures the user of disk images, no cloud-init seed
+	c := testPrepareConfig(t, map[string]interface{}{
+		"disk_image":      true,
+		"ignition_config": map[string]interface{}{"content": "{}"},
+	})
+	if c.CloudInit != nil {
+		t.Fatalf("cloud_init should not be enabled with ignition_config: %#v", c.CloudInit)
+	}
+}
+
+func TestBuilderPrepare_MACAddress(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"mac_address": "52:54:00:aa:bb:cc"}, false},
+		{map[string]interface{}{"mac_address": "52:54:00"}, true},
+		// EUI-64 addresses are not supported by QEMU
+		{map[string]interface{}{"mac_address": "52:54:00:aa:bb:cc:dd:ee"}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"mac_address": "52:54:00:aa:bb:cc:dd:ee"}}}, true},
+		// Conflicts with network_interface
+		{map[string]interface{}{"mac_address": "52:54:00:aa:bb:cc", "network_interface": []map[string]interface{}{{}}}, true},
+	})
+
+	// Default is generated from the build and VM names
+	c := testPrepareConfig(t, map[string]interface{}{"vm_name": "myvm"})
+	if c.MACAddress != generateMACAddress("foo", "myvm", 0) || !strings.HasPrefix(c.MACAddress, "52:54:00:") {
+		t.Fatalf("bad mac_address: %s", c.MACAddress)
+	}
+	if generateMACAddress("foo", "myvm", 0) == generateMACAddress("foo", "othervm", 0) ||
+		generateMACAddress("foo", "myvm", 0) == generateMACAddress("bar", "myvm", 0) ||
+		generateMACAddress("foo", "myvm", 0) == generateMACAddress("foo", "myvm", 1) {
+		t.Fatal("generated MAC addresses should differ")
+	}
+
+	// Explicit
+	c = testPrepareConfig(t, map[string]interface{}{"mac_address": "52:54:00:aa:bb:cc"})
+	iface, _ := c.commNetworkInterface()
+	if iface.MACAddress != "52:54:00:aa:bb:cc" {
+		t.Fatalf("bad network interface mac_address: %s", iface.MACAddress)
+	}
+
+	// Generated for each network interface
+	c = testPrepareConfig(t, map[string]interface{}{
+		"vm_name":           "myvm",
+		"network_interface": []map[string]interface{}{{}, {"mac_address": "52:54:00:aa:bb:cc"}},
+	})
+	if c.NetworkInterfaces[0].MACAddress != generateMACAddress("foo", "myvm", 0) ||
+		c.NetworkInterfaces[1].MACAddress != "52:54:00:aa:bb:cc" {
+		t.Fatalf("bad network interfaces: %#v", c.NetworkInterfaces)
+	}
+
+	// Generated addresses on shared networks are not warned about
+	_, warns, err := testPrepare(map[string]interface{}{
+		"network_interface": []map[string]interface{}{{}, {"backend": "tap", "ifname": "tap0"}},
+	})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(warns) != 0 {
+		t.Fatalf("should not have warnings: %#v", warns)
+	}
+}
+
+func TestBuilderPrepare_NetworkInterface(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{}, {"backend": "tap", "ifname": "tap0", "mac_address": "52:54:00:12:34:56"}}}, false},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "socket", "socket_address": "127.0.0.1:1234"}, {"communicator": true}}}, false},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "vde"}, {"communicator": true}}}, false},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "passt"}}}, false},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "passt"}}, "net_ipv6": true}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "passt"}}, "port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "slirp"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "bridge"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "tap"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "socket"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "socket", "socket_mode": "dgram", "socket_address": "127.0.0.1:1234"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"mac_address": "52:54:00"}}}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"communicator": true}, {"communicator": true}}}, true},
+		// The guest address of the communicator interface can't be found
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "vde"}}}, true},
+		// net_bridge can't be used with network_interface
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{}}, "net_bridge": "virbr0"}, true},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, map[string]interface{}{
+		"net_device":        "e1000",
+		"network_interface": []map[string]interface{}{{}, {"backend": "tap", "ifname": "tap0"}},
+	})
+	iface, id := c.commNetworkInterface()
+	if iface.Backend != "user" || iface.Model != "e1000" || id != "user.0" {
+		t.Fatalf("bad communicator interface %s: %#v", id, iface)
+	}
+}
+
+func TestBuilderPrepare_GuestAddress(t *testing.T) {
+	tap := []map[string]interface{}{{"backend": "tap", "ifname": "tap0"}}
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"guest_address_sources": []string{"dnsmasq", "libvirt", "arp"}}, false},
+		{map[string]interface{}{"guest_address_sources": []string{"dhcp"}}, true},
+		{map[string]interface{}{"guest_address_poll_interval": "2s"}, false},
+		{map[string]interface{}{"guest_address_poll_interval": "-2s"}, true},
+		{map[string]interface{}{"network_interface": tap, "guest_address": "192.168.122.10"}, false},
+		{map[string]interface{}{"network_interface": tap, "guest_address": "fe80::1%tap0"}, false},
+		{map[string]interface{}{"network_interface": tap, "guest_address": "myvm.local"}, true},
+		{map[string]interface{}{"guest_address": "10.0.2.15"}, true},
+		{map[string]interface{}{"network_interface": []map[string]interface{}{{"backend": "vde"}}, "guest_address": "192.168.122.10"}, false},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, nil)
+	if !reflect.DeepEqual(c.GuestAddressSources, []string{"arp"}) {
+		t.Fatalf("bad guest_address_sources: %#v", c.GuestAddressSources)
+	}
+	if c.GuestAddressPollInterval != 10*time.Second {
+		t.Fatalf("bad guest_address_poll_interval: %s", c.GuestAddressPollInterval)
+	}
+
+	c = testPrepareConfig(t, map[string]interface{}{"net_ipv6": true})
+	if !reflect.DeepEqual(c.GuestAddressSources, []string{"arp", "neighbor"}) {
+		t.Fatalf("bad guest_address_sources: %#v", c.GuestAddressSources)
+	}
+}
+
+func TestBuilderPrepare_CPU(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"cpus": 4, "sockets": 1, "cores": 2, "threads": 2}, false},
+		{map[string]interface{}{"cores": 4}, false},
+		{map[string]interface{}{"cpus": 4, "cores": 2}, true},
+		{map[string]interface{}{"sockets": -1}, true},
+		{map[string]interface{}{"cpu_model": "qemu64", "cpu_features": []string{"+vmx", "-hypervisor", "kvm=off"}}, false},
+		{map[string]interface{}{"cpu_model": "qemu64", "cpu_features": []string{"vmx"}}, true},
+		{map[string]interface{}{"accelerator": "xen", "cpu_features": []string{"+vmx"}}, true},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, map[string]interface{}{"cores": 4})
+	if c.CpuCount != 4 || c.Sockets != 1 || c.Cores != 4 || c.Threads != 1 {
+		t.Fatalf("bad topology: cpus %d, sockets %d, cores %d, threads %d", c.CpuCount, c.Sockets, c.Cores, c.Threads)
+	}
+
+	for accelerator, model := range map[string]string{"kvm": "host", "tcg": "max", "xen": ""} {
+		c = testPrepareConfig(t, map[string]interface{}{"accelerator": accelerator})
+		if c.CPUModel != model {
+			t.Fatalf("bad cpu_model with %s: %q", accelerator, c.CPUModel)
+		}
+	}
+}
+
+func TestBuilderPrepare_Devices(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"usb_controller": "nec-usb-xhci", "input_devices": []string{"usb-tablet", "usb-kbd"}}, false},
+		{map[string]interface{}{"input_devices": []string{"virtio-tablet-pci"}}, false},
+		{map[string]interface{}{"input_devices": []string{"ps2-mouse"}}, true},
+		{map[string]interface{}{"usb_controller": "usb-xhci"}, true},
+		{map[string]interface{}{"audio_device": "intel-hda"}, false},
+		{map[string]interface{}{"audio_device": "hda-duplex"}, true},
+		// qemuargs can't replace the devices
+		{map[string]interface{}{"input_devices": []string{"usb-tablet"}, "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"usb_controller": "qemu-xhci", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"audio_device": "intel-hda", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"input_devices": []string{"usb-tablet"}, "qemuargs": [][]string{{"-smp", "2"}}}, false},
+	})
+
+	// Defaults
+	for devices, controller := range map[string]string{"usb-tablet": "qemu-xhci", "virtio-tablet-pci": ""} {
+		c := testPrepareConfig(t, map[string]interface{}{"input_devices": []string{devices}})
+		if c.USBController != controller {
+			t.Fatalf("bad usb_controller with %s: %q", devices, c.USBController)
+		}
+	}
+}
+
+func TestBuilderPrepare_Video(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"vga": "virtio"}, false},
+		{map[string]interface{}{"vga": "vga"}, true},
+		{map[string]interface{}{"vga": "std", "qemu_binary": "qemu-system-aarch64"}, true},
+		{map[string]interface{}{"video_device": "ramfb", "qemu_binary": "qemu-system-aarch64"}, false},
+		{map[string]interface{}{"video_device": "virtio-vga", "qemu_binary": "qemu-system-aarch64"}, true},
+		{map[string]interface{}{"video_device": "virtio-gpu"}, true},
+		{map[string]interface{}{"video_device": "bochs-display", "vga": "none"}, false},
+		// qemuargs can't replace the display adapter
+		{map[string]interface{}{"video_device": "bochs-display", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"video_device": "none", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, false},
+	})
+
+	// Defaults
+	defaults := []struct {
+		Config      map[string]interface{}
+		VGA         string
+		VideoDevice string
+	}{
+		{map[string]interface{}{}, "", ""},
+		{map[string]interface{}{"qemu_binary": "qemu-system-aarch64", "machine_type": "virt"}, "", "virtio-gpu-pci"},
+		{map[string]interface{}{"qemu_binary": "qemu-system-riscv64", "machine_type": "virt-9.0"}, "", "virtio-gpu-pci"},
+		{map[string]interface{}{"qemu_binary": "qemu-system-aarch64", "machine_type": "raspi3b"}, "", ""},
+		{map[string]interface{}{"qemu_binary": "qemu-system-aarch64", "machine_type": "virt", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, "", ""},
+		{map[string]interface{}{"qemu_binary": "qemu-system-riscv64", "machine_type": "sifive_u"}, "", ""},
+		{map[string]interface{}{"video_device": "virtio-vga"}, "none", "virtio-vga"},
+		{map[string]interface{}{"video_device": "none", "qemu_binary": "qemu-system-aarch64", "machine_type": "virt"}, "", "none"},
+	}
+	for _, d := range defaults {
+		c := testPrepareConfig(t, d.Config)
+		if c.VGA != d.VGA || c.VideoDevice != d.VideoDevice {
+			t.Fatalf("bad display adapter with %#v: vga %q, video_device %q", d.Config, c.VGA, c.VideoDevice)
+		}
+	}
+}
+
+func TestBuilderPrepare_RNG(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"rng": false}, false},
+		{map[string]interface{}{"rng": true, "rng_max_bytes": 1024, "rng_period": "2s"}, false},
+		{map[string]interface{}{"rng": true, "rng_max_bytes": -1}, true},
+		{map[string]interface{}{"rng": true, "rng_period": "2s"}, true},
+		{map[string]interface{}{"rng": true, "rng_max_bytes": 1024, "rng_period": "1us"}, true},
+		{map[string]interface{}{"rng": false, "rng_max_bytes": 1024}, true},
+		// qemuargs can't replace the RNG arguments
+		{map[string]interface{}{"rng": true, "qemuargs": [][]string{{"-object", "iothread,id=io0"}}}, true},
+		{map[string]interface{}{"rng": true, "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"rng": true, "qemuargs": [][]string{{"-smp", "2"}}}, false},
+	})
+
+	// Defaults
+	defaults := []struct {
+		Config map[string]interface{}
+		RNG    bool
+	}{
+		{map[string]interface{}{"machine_type": "q35"}, true},
+		{map[string]interface{}{"machine_type": "pc-q35-8.2"}, true},
+		{map[string]interface{}{"machine_type": "virt", "qemu_binary": "qemu-system-aarch64"}, true},
+		{map[string]interface{}{"machine_type": "pc"}, false},
+		{map[string]interface{}{"machine_type": "microvm"}, false},
+		{map[string]interface{}{"machine_type": "q35", "qemuargs": [][]string{{"-object", "iothread,id=io0"}}}, false},
+		{map[string]interface{}{"machine_type": "q35", "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, false},
+	}
+	for _, d := range defaults {
+		c := testPrepareConfig(t, d.Config)
+		if c.RNG.True() != d.RNG {
+			t.Fatalf("bad rng with %#v: %s", d.Config, c.RNG.ToString())
+		}
+	}
+
+	c := testPrepareConfig(t, map[string]interface{}{"rng": true, "rng_max_bytes": 1024})
+	if c.RNGPeriod != time.Second {
+		t.Fatalf("bad rng_period: %s", c.RNGPeriod)
+	}
+}
+
+func TestBuilderPrepare_Memory(t *testing.T) {
+	twoNodes := []map[string]interface{}{{"cpus": "0", "memory": 256}, {"cpus": "1", "memory": 256}}
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"memory_backend": "ram", "memory_prealloc": true}, false},
+		{map[string]interface{}{"memory_backend": "memfd", "memory_hugepages": true, "memory_share": true}, false},
+		{map[string]interface{}{"memory_backend": "file", "memory_backend_path": "/dev/shm"}, false},
+		{map[string]interface{}{"memory_backend": "file"}, true},
+		{map[string]interface{}{"memory_backend": "ram", "memory_hugepages": true}, true},
+		{map[string]interface{}{"memory_backend": "ram", "memory_backend_path": "/dev/shm"}, true},
+		{map[string]interface{}{"memory_backend": "pmem"}, true},
+		{map[string]interface{}{"memory_share": true}, true},
+		{map[string]interface{}{"memory": "4G"}, false},
+		{map[string]interface{}{"memory": "4 GB"}, true},
+		{map[string]interface{}{"memory": "1536K"}, true},
+		{map[string]interface{}{"memory": "1G", "max_memory": "4G", "memory_slots": 4}, false},
+		{map[string]interface{}{"memory": "1G", "max_memory": "1024M"}, true},
+		{map[string]interface{}{"memory": "1G", "max_memory": "4G", "memory_slots": 300}, true},
+		{map[string]interface{}{"memory_slots": 2}, true},
+		{map[string]interface{}{"memory_balloon": true}, false},
+		{map[string]interface{}{"memory_balloon": true, "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": twoNodes}, false},
+		// The memory of the nodes must add up to memory
+		{map[string]interface{}{"cpus": 2, "memory": 1024, "numa_node": twoNodes}, true},
+		// All the CPUs must be assigned once
+		{map[string]interface{}{"cpus": 4, "memory": 512, "numa_node": twoNodes}, true},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": []map[string]interface{}{{"cpus": "0-1", "memory": 256}, {"cpus": "1", "memory": 256}}}, true},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": []map[string]interface{}{{"cpus": "0-2", "memory": 512}}}, true},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": []map[string]interface{}{{"cpus": "0-1", "memory": 512, "host_nodes": "a"}}}, true},
+		{map[string]interface{}{"cpus": 2, "memory": "1G", "numa_node": []map[string]interface{}{{"cpus": "0", "memory": "512M"}, {"cpus": "1", "memory": "524288K"}}}, false},
+		{map[string]interface{}{"cpus": 2, "memory": "1G", "numa_node": []map[string]interface{}{{"cpus": "0-1", "memory": "1 GB"}}}, true},
+		// qemuargs can't replace the memory backends
+		{map[string]interface{}{"memory_backend": "ram", "qemuargs": [][]string{{"-machine", "q35"}}}, true},
+		{map[string]interface{}{"memory_backend": "ram", "qemuargs": [][]string{{"-object", "iothread,id=io0"}}}, true},
+		{map[string]interface{}{"memory_backend": "ram", "qemuargs": [][]string{{"-numa", "node"}}}, false},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": twoNodes, "qemuargs": [][]string{{"-machine", "q35"}}}, false},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": twoNodes, "qemuargs": [][]string{{"-object", "iothread,id=io0"}}}, true},
+		{map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": twoNodes, "qemuargs": [][]string{{"-numa", "node"}}}, true},
+	})
+
+	// Units
+	c := testPrepareConfig(t, map[string]interface{}{"memory": "2G", "max_memory": "8G"})
+	if c.MemorySize != "2048M" || c.MaxMemory != "8192M" || c.MemorySlots != 1 {
+		t.Fatalf("bad memory: %s, max_memory: %s, memory_slots: %d", c.MemorySize, c.MaxMemory, c.MemorySlots)
+	}
+	if c.memoryMiB != 2048 || c.maxMemoryMiB != 8192 {
+		t.Fatalf("bad memory in megabytes: %d, max_memory: %d", c.memoryMiB, c.maxMemoryMiB)
+	}
+	c = testPrepareConfig(t, map[string]interface{}{"cpus": 2, "memory": "3G", "numa_node": []map[string]interface{}{{"cpus": "0", "memory": 1024}, {"cpus": "1", "memory": "2G"}}})
+	if c.NUMANodes[0].memoryMiB != 1024 || c.NUMANodes[1].Memory != "2048M" || c.NUMANodes[1].memoryMiB != 2048 {
+		t.Fatalf("bad numa_node memory: %#v", c.NUMANodes)
+	}
+
+	// Numbers are in megabytes, and too small sizes are replaced with a warning
+	c = testPrepareConfig(t, map[string]interface{}{"memory": 1024})
+	if c.MemorySize != "1024M" {
+		t.Fatalf("bad memory: %s", c.MemorySize)
+	}
+	c, warns, err := testPrepare(map[string]interface{}{"memory": 8})
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(warns) == 0 || c.MemorySize != "512M" {
+		t.Fatalf("should have a warning and the default memory: %#v, %s", warns, c.MemorySize)
+	}
+
+	// Defaults
+	c = testPrepareConfig(t, map[string]interface{}{"cpus": 2, "memory": 512, "numa_node": twoNodes})
+	if c.MemoryBackend != "ram" {
+		t.Fatalf("bad memory_backend: %s", c.MemoryBackend)
+	}
+
+	c = testPrepareConfig(t, map[string]interface{}{"memory_backend": "file", "memory_hugepages": true})
+	if c.MemoryBackendPath != "/dev/hugepages" {
+		t.Fatalf("bad memory_backend_path: %s", c.MemoryBackendPath)
+	}
+}
+
+func TestBuilderPrepare_SecureBoot(t *testing.T) {
+	dir := t.TempDir()
+	code := filepath.Join(dir, "OVMF_CODE.secboot.fd")
+	vars := filepath.Join(dir, "OVMF_VARS.ms.fd")
+	pk := filepath.Join(dir, "pk.pem")
+	for _, path := range []string{code, vars, pk} {
+		if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	firmware := map[string]interface{}{"efi_firmware_code": code, "efi_firmware_vars": vars}
+	with := func(m map[string]interface{}) map[string]interface{} {
+		for k, v := range firmware {
+			m[k] = v
+		}
+		return m
+	}
+	testPrepareCases(t, []prepareTestCase{
+		{with(map[string]interface{}{}), false},
+		{with(map[string]interface{}{"secure_boot": true}), false},
+		{with(map[string]interface{}{"secure_boot": true, "machine_type": "pc-q35-8.2"}), false},
+		{with(map[string]interface{}{"secure_boot": true, "machine_type": "pc"}), true},
+		{with(map[string]interface{}{"secure_boot": true, "qemu_binary": "qemu-system-aarch64"}), true},
+		// qemuargs can't replace the SMM arguments
+		{with(map[string]interface{}{"secure_boot": true, "qemuargs": [][]string{{"-machine", "q35"}}}), true},
+		{with(map[string]interface{}{"secure_boot": true, "qemuargs": [][]string{{"-global", "ICH9-LPC.disable_s3=1"}}}), true},
+		{with(map[string]interface{}{"secure_boot": true, "qemuargs": [][]string{{"-drive", "file=disk.qcow2,if=virtio"}}}), true},
+		{with(map[string]interface{}{"secure_boot": true, "qemuargs": [][]string{{"-smp", "2"}}}), false},
+		{with(map[string]interface{}{"firmware": code}), true},
+		{map[string]interface{}{"efi_firmware_code": code}, true},
+		{map[string]interface{}{"efi_firmware_code": code, "efi_firmware_vars": filepath.Join(dir, "missing.fd")}, true},
+		{with(map[string]interface{}{"secure_boot": true, "secure_boot_keys": map[string]interface{}{"pk": pk}}), false},
+		{with(map[string]interface{}{"secure_boot": true, "secure_boot_keys": map[string]interface{}{"kek": []string{pk}}}), true},
+		{with(map[string]interface{}{"secure_boot": true, "secure_boot_keys": map[string]interface{}{"pk": pk, "db": []string{filepath.Join(dir, "missing.pem")}}}), true},
+		{with(map[string]interface{}{"secure_boot": true, "secure_boot_keys": map[string]interface{}{"pk": pk, "owner_guid": "packer"}}), true},
+		{with(map[string]interface{}{"secure_boot_keys": map[string]interface{}{"pk": pk}}), true},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, with(map[string]interface{}{
+		"secure_boot":      true,
+		"secure_boot_keys": map[string]interface{}{"pk": pk},
+	}))
+	if c.MachineType != "q35" {
+		t.Fatalf("bad machine_type: %s", c.MachineType)
+	}
+	if c.SecureBootKeys.OwnerGUID != generateGUID(c.VMName) {
+		t.Fatalf("bad owner_guid: %s", c.SecureBootKeys.OwnerGUID)
+	}
+}
+
+func TestBuilderPrepare_DisplayProtocol(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"display_protocol": "vnc"}, false},
+		{map[string]interface{}{"display_protocol": "spice", "spice_use_password": true}, false},
+		{map[string]interface{}{"display_protocol": "rdp"}, true},
+		{map[string]interface{}{"spice_use_password": true}, true},
+		{map[string]interface{}{"display_protocol": "spice", "spice_port_min": 80}, true},
+		{map[string]interface{}{"display_protocol": "spice", "spice_port_min": 5990, "spice_port_max": 5950}, true},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, map[string]interface{}{"display_protocol": "spice", "spice_use_password": true})
+	if c.SPICEBindAddress != "127.0.0.1" || c.SPICEPortMin != 5900 || c.SPICEPortMax != 6000 {
+		t.Fatalf("bad SPICE address: %s, %d-%d", c.SPICEBindAddress, c.SPICEPortMin, c.SPICEPortMax)
+	}
+	if !c.QMPEnable {
+		t.Fatal("spice_use_password should enable QMP")
+	}
+}
+
+func TestBuilderPrepare_VNCSocket(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"vnc_socket": true, "vnc_socket_path": "/tmp/packer.vnc"}, false},
+		// vnc_socket_path requires vnc_socket
+		{map[string]interface{}{"vnc_socket_path": "/tmp/packer.vnc"}, true},
+		// Unix socket paths are limited to 103 characters
+		{map[string]interface{}{"vnc_socket": true, "vnc_socket_path": "/tmp/" + strings.Repeat("a", 95) + ".vnc"}, true},
+		{map[string]interface{}{"vnc_socket": true, "output_directory": "/tmp/" + strings.Repeat("a", 100)}, true},
+		{map[string]interface{}{"vnc_socket": true, "vnc_socket_path": "/tmp/" + strings.Repeat("a", 94) + ".vnc"}, false},
+	})
+
+	// Defaults
+	c := testPrepareConfig(t, map[string]interface{}{"vnc_socket": true})
+	if c.VNCSocketPath != filepath.Join(c.OutputDir, c.VMName+".vnc") {
+		t.Fatalf("bad vnc_socket_path: %s", c.VNCSocketPath)
+	}
+}
+
+// testGeneratedData returns the build variables of every build, followed by
+// names.
+func testGeneratedData(names ...string) []string {
+	return append([]string{"DiskPaths", "GuestAddress", "HTTPIP", "QMPSocket", "SPICEAddress", "SPICEPassword", "SSHHostPort", "VNCPassword", "VNCPort", "VNCSocket"}, names...)
+}
+
+func TestBuilderPrepare_GeneratedData(t *testing.T) {
+	_, generatedData := testPrepareBuilder(t, nil)
+	if !reflect.DeepEqual(generatedData, testGeneratedData()) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestBuilderPrepare_PortForward(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80}, {"name": "dns", "guest_port": 53, "protocol": "udp", "host_port": 5353}}}, false},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80, "host_port_min": 8000, "host_port_max": 8100}}}, false},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web-ui", "guest_port": 80}}}, true},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web"}}}, true},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80, "protocol": "sctp"}}}, true},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80, "host_port_min": 8100, "host_port_max": 8000}}}, true},
+		{map[string]interface{}{"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80}, {"name": "web", "guest_port": 8080}}}, true},
+		// A user network interface is required
+		{map[string]interface{}{
+			"port_forward":      []map[string]interface{}{{"name": "web", "guest_port": 80}},
+			"network_interface": []map[string]interface{}{{"backend": "tap", "ifname": "tap0"}},
+		}, true},
+	})
+
+	// Defaults and generated data
+	b, generatedData := testPrepareBuilder(t, map[string]interface{}{
+		"port_forward": []map[string]interface{}{{"name": "web", "guest_port": 80}},
+	})
+	pf := b.config.PortForwards[0]
+	if pf.Protocol != "tcp" || pf.HostPortMin != 2222 || pf.HostPortMax != 4444 {
+		t.Fatalf("bad port_forward defaults: %#v", pf)
+	}
+	if !reflect.DeepEqual(generatedData, testGeneratedData("PortForward_web")) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestBuilderPrepare_NetworkMode(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"network_mode": ""}, false},
+		{map[string]interface{}{"network_mode": "default"}, false},
+		{map[string]interface{}{"network_mode": "restricted"}, false},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:80", "10.1.1.1:443"}}, false},
+		{map[string]interface{}{"network_mode": "isolated"}, true},
+		{map[string]interface{}{"network_mode": "default", "network_allowlist": []string{"mirror.example.com:80"}}, true},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com"}}, true},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:http"}}, true},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:80", "10.1.1.1:80"}}, true},
+		// The port of the HTTP server is exposed at the same address
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:8080"}}, false},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:8080"}, "http_directory": "testdata"}, true},
+		{map[string]interface{}{"network_mode": "restricted", "network_allowlist": []string{"mirror.example.com:8080"}, "http_directory": "testdata", "http_port_min": 9000, "http_port_max": 9100}, false},
+		// All the network interfaces must use the user backend
+		{map[string]interface{}{
+			"network_mode":      "restricted",
+			"network_interface": []map[string]interface{}{{}, {"backend": "bridge", "bridge": "virbr0"}},
+		}, true},
+	})
+}
+
+func TestBuilderPrepare_GuestForward(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{
+			{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"},
+			{"name": "cache", "guest_address": "10.0.2.101:80", "command": "nc 127.0.0.1 8080"},
+		}}, false},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy-1", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "192.168.1.100:3128", "target": "127.0.0.1:3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.2:3128", "target": "127.0.0.1:3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.100", "target": "127.0.0.1:3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.100:3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128", "command": "nc 127.0.0.1 3128"}}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{
+			{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"},
+			{"name": "proxy", "guest_address": "10.0.2.101:3128", "target": "127.0.0.1:3128"},
+		}}, true},
+		{map[string]interface{}{"guest_forward": []map[string]interface{}{
+			{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"},
+			{"name": "cache", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"},
+		}}, true},
+		// The restricted network_mode address is reserved
+		{map[string]interface{}{
+			"network_mode":  "restricted",
+			"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.254:3128", "target": "127.0.0.1:3128"}},
+		}, true},
+	})
+
+	// Generated data
+	_, generatedData := testPrepareBuilder(t, map[string]interface{}{
+		"guest_forward": []map[string]interface{}{{"name": "proxy", "guest_address": "10.0.2.100:3128", "target": "127.0.0.1:3128"}},
+	})
+	if !reflect.DeepEqual(generatedData, testGeneratedData("GuestForward_proxy")) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestBuilderPrepare_SharedFolder(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte{}, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p", "read_only": true}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p", "security_model": "none"}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "memory_backend": "file", "memory_backend_path": dir}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "memory_backend": "ram"}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "smb"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "security_model": "none"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p", "security_model": "mapped"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p", "tag": strings.Repeat("a", 32)}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs-1", "host_path": dir}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": file}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": filepath.Join(dir, "missing")}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}, {"name": "inputs", "host_path": dir}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "tag": "data"}, {"name": "outputs", "host_path": dir, "tag": "data"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "tag": "inputs.v2-ro"}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "tag": "inputs,queue-size=1"}}}, true},
+		// qemuargs can't replace the shared folder arguments
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "qemuargs": [][]string{{"-chardev", "pty,id=pty0"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "qemuargs": [][]string{{"-object", "iothread,id=io0"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "qemuargs": [][]string{{"-machine", "q35"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "qemuargs": [][]string{{"-fsdev", "local,id=fs0,path=/srv"}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p"}}, "qemuargs": [][]string{{"-fsdev", "local,id=fs0,path=/srv"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p"}}, "qemuargs": [][]string{{"-chardev", "pty,id=pty0"}}}, false},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir}}, "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+		{map[string]interface{}{"shared_folder": []map[string]interface{}{{"name": "inputs", "host_path": dir, "type": "9p"}}, "qemuargs": [][]string{{"-device", "virtio-net,netdev=user.0"}}}, true},
+	})
+
+	// Defaults
+	b, generatedData := testPrepareBuilder(t, map[string]interface{}{
+		"shared_folder": []map[string]interface{}{
+			{"name": "inputs", "host_path": dir},
+			{"name": "outputs", "host_path": dir, "type": "9p", "tag": "out"},
+		},
+	})
+	c := b.config
+	if c.SharedFolders[0].Tag != "inputs" {
+		t.Fatalf("bad tag: %s", c.SharedFolders[0].Tag)
+	}
+	if c.SharedFolders[0].Type != "virtiofs" || c.SharedFolders[0].SecurityModel != "" {
+		t.Fatalf("bad virtiofs shared folder: %#v", c.SharedFolders[0])
+	}
+	if c.SharedFolders[1].SecurityModel != "mapped-xattr" {
+		t.Fatalf("bad security_model: %s", c.SharedFolders[1].SecurityModel)
+	}
+	if c.MemoryBackend != "memfd" || !c.MemoryShare {
+		t.Fatalf("virtiofs should share the memory: %s, %t", c.MemoryBackend, c.MemoryShare)
+	}
+	if !reflect.DeepEqual(generatedData, testGeneratedData("SharedFolder_inputs", "SharedFolder_outputs")) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestBuilderPrepare_FwCfg(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/com.example/a", "string": "foo"}}}, false},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/com.example/a", "file": "testdata/floppies/bar.bat"}}}, false},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "etc/foo", "string": "foo"}}}, true},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/a,file=/etc/shadow", "string": "foo"}}}, true},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/" + strings.Repeat("a", 52), "string": "foo"}}}, true},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/com.example/a"}}}, true},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/com.example/a", "string": "foo", "file": "testdata/floppies/bar.bat"}}}, true},
+		{map[string]interface{}{"fw_cfg": []map[string]interface{}{{"name": "opt/com.example/a", "file": "testdata/missing"}}}, true},
+	})
+}
+
+func TestBuilderPrepare_SMBIOS(t *testing.T) {
+	testPrepareCases(t, []prepareTestCase{
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 1, "fields": map[string]string{"serial": "1234"}}}}, false},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 11, "values": []string{"io.systemd.credential:foo=bar"}}}}, false},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 5, "fields": map[string]string{"serial": "1234"}}}}, true},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 1}}}, true},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 1, "fields": map[string]string{"serial=1,uuid": "1234"}}}}, true},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 1, "values": []string{"foo"}, "fields": map[string]string{"serial": "1234"}}}}, true},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 11}}}, true},
+		{map[string]interface{}{"smbios": []map[string]interface{}{{"type": 11, "values": []string{"foo"}, "fields": map[string]string{"serial": "1234"}}}}, true},
+	})
+}
+
 func TestBuilderPrepare_FloppyFiles(t *testing.T) {
 	var c Config
 	config := testConfig()
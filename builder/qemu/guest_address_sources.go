@@ -0,0 +1,199 @@
+package qemu
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// guestAddressSources are the sources the guest address can be looked up
+// from, by the MAC address of the network interface.
+var guestAddressSources = map[string]bool{
+	"arp":      true,
+	"neighbor": true,
+	"dnsmasq":  true,
+	"libvirt":  true,
+}
+
+// The default locations of the dnsmasq lease file, depending on the
+// distribution.
+var dnsmasqLeaseFiles = []string{
+	"/var/lib/misc/dnsmasq.leases",
+	"/var/lib/dnsmasq/dnsmasq.leases",
+}
+
+// libvirtDnsmasqDir holds the status files of the dnsmasq instances run by
+// libvirt, named after the bridge of each network.
+const libvirtDnsmasqDir = "/var/lib/libvirt/dnsmasq"
+
+// lookupGuestAddress looks up the address of the lowercase macAddress from
+// source.
+func lookupGuestAddress(source string, bridgeName string, macAddress string, dnsmasqLeaseFile string) (string, error) {
+	switch source {
+	case "arp":
+		return getDeviceIPAddress(bridgeName, macAddress)
+	case "neighbor":
+		return getNeighborIPv6Address(bridgeName, macAddress)
+	case "dnsmasq":
+		return getDnsmasqLeaseAddress(dnsmasqLeaseFile, macAddress)
+	case "libvirt":
+		return getLibvirtLeaseAddress(bridgeName, macAddress)
+	}
+	return "", fmt.Errorf("unknown guest address source %q", source)
+}
+
+func getDnsmasqLeaseAddress(leaseFile string, macAddress string) (string, error) {
+	leaseFiles := dnsmasqLeaseFiles
+	if leaseFile != "" {
+		leaseFiles = []string{leaseFile}
+	}
+
+	for _, path := range leaseFiles {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) && leaseFile == "" {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer f.Close()
+		return parseDnsmasqLeases(f, macAddress)
+	}
+
+	return "", fmt.Errorf("could not find a dnsmasq lease file in %s", strings.Join(leaseFiles, ", "))
+}
+
+// parseDnsmasqLeases returns the address of the most recent IPv4 lease of
+// macAddress.
+func parseDnsmasqLeases(r io.Reader, macAddress string) (string, error) {
+	// The dnsmasq lease file is normally something alike:
+	//
+	// 		1700000000 52:54:00:12:34:56 192.168.122.111 myvm 01:52:54:00:12:34:56
+	// 		duid 00:01:00:01:2c:4f:5a:3b:52:54:00:ab:cd:ef
+	// 		1700000000 1234567 fd00::111 myvm 00:01:00:01:2c:4f:5a:3b:52:54:00:12:34:56
+	//
+	// The IPv6 leases, after the duid line, are identified by their IAID
+	// rather than the MAC address, so they can't be matched.
+
+	const (
+		ExpiryIndex int = iota
+		HWAddressIndex
+		IPAddressIndex
+	)
+
+	address := ""
+	var latest int64 = -1
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[0] == "duid" {
+			continue
+		}
+		if strings.ToLower(fields[HWAddressIndex]) != macAddress {
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[ExpiryIndex], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the dnsmasq lease expiry %s: %w", fields[ExpiryIndex], err)
+		}
+		// Leases that never expire have a 0 expiry.
+		if expiry == 0 {
+			expiry = 1<<63 - 1
+		}
+		if expiry > latest {
+			latest = expiry
+			address = fields[IPAddressIndex]
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", fmt.Errorf("failed to read the dnsmasq leases: %w", err)
+	}
+
+	if address == "" {
+		return "", fmt.Errorf("could not find a dnsmasq lease for %s", macAddress)
+	}
+	return address, nil
+}
+
+func getLibvirtLeaseAddress(bridgeName string, macAddress string) (string, error) {
+	pattern := filepath.Join(libvirtDnsmasqDir, "*.status")
+	if bridgeName != "" {
+		pattern = filepath.Join(libvirtDnsmasqDir, bridgeName+".status")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		address, err := parseLibvirtStatus(f, macAddress)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if address != "" {
+			return address, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find a libvirt lease for %s in %s", macAddress, pattern)
+}
+
+// libvirtLease is a lease of the libvirt dnsmasq status files.
+type libvirtLease struct {
+	IPAddress  string `json:"ip-address"`
+	MACAddress string `json:"mac-address"`
+	ExpiryTime int64  `json:"expiry-time"`
+}
+
+// parseLibvirtStatus returns the address of the most recent lease of
+// macAddress, or an empty address when it has none.
+func parseLibvirtStatus(r io.Reader, macAddress string) (string, error) {
+	// The status file is a JSON list of leases, alike:
+	//
+	// 		[
+	// 		  {
+	// 		    "ip-address": "192.168.122.111",
+	// 		    "mac-address": "52:54:00:12:34:56",
+	// 		    "hostname": "myvm",
+	// 		    "expiry-time": 1700000000
+	// 		  }
+	// 		]
+	//
+	// The file is empty when there are no leases.
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return "", nil
+	}
+
+	var leases []libvirtLease
+	if err := json.Unmarshal(data, &leases); err != nil {
+		return "", err
+	}
+
+	address := ""
+	var latest int64 = -1
+	for _, lease := range leases {
+		if strings.ToLower(lease.MACAddress) != macAddress {
+			continue
+		}
+		if lease.ExpiryTime > latest {
+			latest = lease.ExpiryTime
+			address = lease.IPAddress
+		}
+	}
+	return address, nil
+}
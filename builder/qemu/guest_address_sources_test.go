@@ -0,0 +1,87 @@
+package qemu
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDnsmasqLeases(t *testing.T) {
+	leases := `1700000000 52:54:00:12:34:56 192.168.122.111 myvm 01:52:54:00:12:34:56
+1700000100 52:54:00:ab:cd:ef 192.168.122.112 other *
+1700000200 52:54:00:12:34:56 192.168.122.113 myvm 01:52:54:00:12:34:56
+duid 00:01:00:01:2c:4f:5a:3b:52:54:00:ab:cd:ef
+1700000300 1234567 fd00::111 myvm 00:01:00:01:2c:4f:5a:3b:52:54:00:12:34:56
+`
+
+	address, err := parseDnsmasqLeases(strings.NewReader(leases), "52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if address != "192.168.122.113" {
+		t.Fatalf("bad address: %s", address)
+	}
+
+	if _, err := parseDnsmasqLeases(strings.NewReader(leases), "52:54:00:00:00:01"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestGetDnsmasqLeaseAddress(t *testing.T) {
+	leaseFile := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	if err := os.WriteFile(leaseFile, []byte("0 52:54:00:12:34:56 192.168.122.111 myvm *\n"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	address, err := getDnsmasqLeaseAddress(leaseFile, "52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if address != "192.168.122.111" {
+		t.Fatalf("bad address: %s", address)
+	}
+
+	if _, err := getDnsmasqLeaseAddress(leaseFile+".missing", "52:54:00:12:34:56"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestParseLibvirtStatus(t *testing.T) {
+	status := `[
+  {
+    "ip-address": "192.168.122.111",
+    "mac-address": "52:54:00:12:34:56",
+    "hostname": "myvm",
+    "expiry-time": 1700000200
+  },
+  {
+    "ip-address": "192.168.122.112",
+    "mac-address": "52:54:00:12:34:56",
+    "expiry-time": 1700000100
+  },
+  {
+    "iaid": "1234567",
+    "ip-address": "fd00::111",
+    "client-id": "00:01:00:01:2c:4f:5a:3b:52:54:00:12:34:56",
+    "expiry-time": 1700000300
+  }
+]`
+
+	address, err := parseLibvirtStatus(strings.NewReader(status), "52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if address != "192.168.122.111" {
+		t.Fatalf("bad address: %s", address)
+	}
+
+	address, err = parseLibvirtStatus(strings.NewReader(""), "52:54:00:12:34:56")
+	if err != nil || address != "" {
+		t.Fatalf("empty status should have no address: %q, %v", address, err)
+	}
+
+	if _, err := parseLibvirtStatus(strings.NewReader("{"), "52:54:00:12:34:56"); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,143 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The names of the fw_cfg items and of the smbios fields are passed to QEMU
+// unescaped, so they can't hold commas.
+var (
+	fwCfgNameRe   = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+	smbiosFieldRe = regexp.MustCompile(`^[a-z][a-z_]*$`)
+)
+
+// fwCfgNameMaxLength is the maximum length of a fw_cfg item name, QEMU
+// reserving 56 bytes for it including the terminating NUL.
+const fwCfgNameMaxLength = 55
+
+var smbiosTypes = map[int]bool{
+	0:  true,
+	1:  true,
+	2:  true,
+	3:  true,
+	4:  true,
+	11: true,
+	17: true,
+}
+
+// A `fw_cfg` block passes a file or a string to the guest through the QEMU
+// firmware configuration device, where it can be read from
+// `/sys/firmware/qemu_fw_cfg/by_name/<name>/raw` on Linux guests.
+//
+// In HCL2:
+// ```hcl
+//   fw_cfg {
+//     name   = "opt/com.example/build"
+//     string = "http://{{ .HTTPIP }}:{{ .HTTPPort }}/build.json"
+//   }
+// ```
+type FwCfgConfig struct {
+	// The name of the item. It must start with `opt/`, be at most 55
+	// characters long and only contain letters, digits, `_`, `.`, `-` and
+	// `/`. It is recommended to use a reverse domain name as the
+	// second component, like `opt/com.example/config`.
+	Name string `mapstructure:"name" required:"true"`
+	// The path to a file on the build machine to pass as the item content.
+	// Conflicts with `string`.
+	File string `mapstructure:"file" required:"false"`
+	// The content of the item. This is a template engine and allows access
+	// to the following variables: `{{ .HTTPIP }}`, `{{ .HTTPHost }}`,
+	// `{{ .HTTPPort }}` and `{{ .Name }}`. Conflicts with `file`.
+	String string `mapstructure:"string" required:"false"`
+}
+
+func (c *FwCfgConfig) Prepare() []error {
+	var errs []error
+
+	if !strings.HasPrefix(c.Name, "opt/") {
+		errs = append(errs, fmt.Errorf("fw_cfg name %q must start with opt/", c.Name))
+	}
+	if !fwCfgNameRe.MatchString(c.Name) {
+		errs = append(errs, fmt.Errorf("fw_cfg name %q must only contain letters, digits, _, ., - and /", c.Name))
+	}
+	if len(c.Name) > fwCfgNameMaxLength {
+		errs = append(errs, fmt.Errorf("fw_cfg name %q must be at most %d characters long", c.Name, fwCfgNameMaxLength))
+	}
+
+	if (c.File == "") == (c.String == "") {
+		errs = append(errs, fmt.Errorf("fw_cfg %q: exactly one of file or string must be set", c.Name))
+	} else if c.File != "" {
+		if _, err := os.Stat(c.File); err != nil {
+			errs = append(errs, fmt.Errorf("fw_cfg %q: file %s is not accessible: %s", c.Name, c.File, err))
+		}
+	}
+
+	return errs
+}
+
+// A `smbios` block adds an SMBIOS structure to the firmware tables of the
+// VM. Type 11 structures hold OEM strings, which can be used to pass
+// [systemd credentials](https://systemd.io/CREDENTIALS/) to the guest.
+//
+// In HCL2:
+// ```hcl
+//   smbios {
+//     type   = 1
+//     fields = {
+//       manufacturer = "Example"
+//       serial       = "1234"
+//     }
+//   }
+//
+//   smbios {
+//     type   = 11
+//     values = ["io.systemd.credential:hostname=builder"]
+//   }
+// ```
+type SMBIOSConfig struct {
+	// The SMBIOS structure type. Allowed values are `0`, `1`, `2`, `3`, `4`,
+	// `11` and `17`.
+	Type int `mapstructure:"type" required:"true"`
+	// The fields of the structure, as documented for the -smbios option of
+	// QEMU, for example `vendor` for type 0 or `serial` for type 1. Can't be
+	// used with type 11.
+	Fields map[string]string `mapstructure:"fields" required:"false"`
+	// The OEM strings of a type 11 structure. Only allowed with type 11.
+	Values []string `mapstructure:"values" required:"false"`
+}
+
+func (c *SMBIOSConfig) Prepare() []error {
+	var errs []error
+
+	if !smbiosTypes[c.Type] {
+		errs = append(errs, fmt.Errorf("smbios type %d is not supported, only 0, 1, 2, 3, 4, 11 and 17 are allowed", c.Type))
+	}
+
+	if c.Type == 11 {
+		if len(c.Fields) > 0 {
+			errs = append(errs, errors.New("smbios type 11 only accepts values"))
+		}
+		if len(c.Values) == 0 {
+			errs = append(errs, errors.New("smbios type 11 requires values"))
+		}
+	} else {
+		if len(c.Values) > 0 {
+			errs = append(errs, errors.New("smbios values can only be used with type 11"))
+		}
+		if len(c.Fields) == 0 {
+			errs = append(errs, fmt.Errorf("smbios type %d requires fields", c.Type))
+		}
+		for field := range c.Fields {
+			if !smbiosFieldRe.MatchString(field) {
+				errs = append(errs, fmt.Errorf("smbios field %q is invalid, fields are lowercase names like serial", field))
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,104 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// userNetwork is the default network of the QEMU user mode networking.
+var userNetwork = &net.IPNet{IP: net.IPv4(10, 0, 2, 0), Mask: net.CIDRMask(24, 32)}
+
+// userNetworkGuestIP is the address the guest gets from the DHCP server of
+// the QEMU user mode networking.
+const userNetworkGuestIP = "10.0.2.15"
+
+// A `guest_forward` block makes a virtual address of the user network
+// forward the TCP connections of the guest to an address reachable from the
+// host, or to a command run on the host for each connection. This allows the
+// guest to use a cache or a proxy of the host without `net_bridge`.
+//
+// The address is available as `{{ .GuestForwards.<name> }}` in
+// `boot_command`, `kernel_cmdline`, `fw_cfg` and `qemuargs`, and to
+// provisioners as the `GuestForward_<name>` build variable, for example
+// ``{{ build `GuestForward_proxy` }}`` in JSON or `${build.GuestForward_proxy}`
+// in HCL2.
+//
+// In HCL2:
+// ```hcl
+//   guest_forward {
+//     name          = "proxy"
+//     guest_address = "10.0.2.100:3128"
+//     target        = "127.0.0.1:3128"
+//   }
+// ```
+type GuestForwardConfig struct {
+	// The name of the forward, made of letters, digits and underscores. It
+	// must be unique.
+	Name string `mapstructure:"name" required:"true"`
+	// The `ip:port` address the guest connects to. The IP address must be in
+	// the `10.0.2.0/24` user network, other than the `10.0.2.2` host and
+	// `10.0.2.3` DNS server addresses.
+	GuestAddress string `mapstructure:"guest_address" required:"true"`
+	// The `host:port` address to forward the connections to, reached from
+	// the host. The connections are relayed with `socat`, or `nc` when
+	// `socat` is not installed, which must be in the `PATH` of the host.
+	// Conflicts with `command`.
+	Target string `mapstructure:"target" required:"false"`
+	// The command to run on the host for each connection, with the
+	// connection on its standard input and output, for example
+	// `nc 127.0.0.1 3128`. Conflicts with `target`.
+	Command string `mapstructure:"command" required:"false"`
+}
+
+func (c *GuestForwardConfig) Prepare() []error {
+	var errs []error
+
+	if !portForwardNameRe.MatchString(c.Name) {
+		errs = append(errs, fmt.Errorf("guest_forward name %q must only contain letters, digits and underscores", c.Name))
+	}
+
+	host, port, err := net.SplitHostPort(c.GuestAddress)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("guest_forward %q: guest_address %q is invalid: %s", c.Name, c.GuestAddress, err))
+	} else {
+		ip := net.ParseIP(host)
+		if ip == nil || !userNetwork.Contains(ip) || ip.Equal(net.IPv4(10, 0, 2, 2)) || ip.Equal(net.IPv4(10, 0, 2, 3)) {
+			errs = append(errs, fmt.Errorf("guest_forward %q: guest_address %q must be in the 10.0.2.0/24 network, other than 10.0.2.2 and 10.0.2.3", c.Name, c.GuestAddress))
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			errs = append(errs, fmt.Errorf("guest_forward %q: guest_address %q has an invalid port", c.Name, c.GuestAddress))
+		}
+	}
+
+	if (c.Target == "") == (c.Command == "") {
+		errs = append(errs, fmt.Errorf("guest_forward %q: exactly one of target or command must be set", c.Name))
+	} else if c.Target != "" {
+		if _, _, err := net.SplitHostPort(c.Target); err != nil {
+			errs = append(errs, fmt.Errorf("guest_forward %q: target %q is invalid: %s", c.Name, c.Target, err))
+		}
+	}
+
+	return errs
+}
+
+// guestfwdOption returns the guestfwd option of the -netdev argument. A
+// target is reached through a relay command, run for each connection.
+func (c *GuestForwardConfig) guestfwdOption() (string, error) {
+	command := c.Command
+	if command == "" {
+		var err error
+		command, err = relayCommand(c.Target)
+		if err != nil {
+			return "", fmt.Errorf("Error forwarding %s to the guest: %s", c.Target, err)
+		}
+	}
+	return fmt.Sprintf("guestfwd=tcp:%s-cmd:%s", c.GuestAddress, qemuEscape(command)), nil
+}
+
+// generatedDataName returns the name of the build variable holding the guest
+// address of the forward.
+func (c *GuestForwardConfig) generatedDataName() string {
+	return "GuestForward_" + c.Name
+}
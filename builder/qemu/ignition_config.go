@@ -0,0 +1,63 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ignitionFwCfgName is the fw_cfg item the Ignition qemu platform reads its
+// config from.
+const ignitionFwCfgName = "opt/com.coreos/config"
+
+// ignitionFwCfgArchs are the architectures on which Ignition reads its config
+// from fw_cfg. On the others, it reads it from a virtio-blk drive with the
+// ignition serial.
+var ignitionFwCfgArchs = map[string]bool{
+	"x86_64":  true,
+	"i386":    true,
+	"aarch64": true,
+}
+
+// The `ignition_config` block passes an [Ignition](https://coreos.github.io/ignition/)
+// config to the VM, to build Fedora CoreOS or Flatcar Container Linux images.
+// On `x86_64`, `i386` and `aarch64`, the config is passed through fw_cfg as
+// `opt/com.coreos/config`. On other architectures, it is attached as a
+// read-only virtio-blk drive with the `ignition` serial.
+//
+// When the ssh communicator is used without `ssh_private_key_file`, Packer
+// generates a temporary SSH key and authorizes it for `ssh_username` in the
+// `passwd` section of the config, creating the user if needed.
+//
+// In HCL2:
+// ```hcl
+//   ignition_config {
+//     file   = "config.bu"
+//     butane = true
+//   }
+// ```
+type IgnitionConfig struct {
+	// The path to the Ignition config file. Conflicts with `content`.
+	File string `mapstructure:"file" required:"false"`
+	// The Ignition config. Conflicts with `file`.
+	Content string `mapstructure:"content" required:"false"`
+	// The config is a [Butane](https://coreos.github.io/butane/) config, to
+	// translate to an Ignition config with the `butane` binary, which must be
+	// available in the PATH of the build machine. Defaults to `false`.
+	Butane bool `mapstructure:"butane" required:"false"`
+}
+
+func (c *IgnitionConfig) Prepare() []error {
+	var errs []error
+
+	if (c.File == "") == (c.Content == "") {
+		errs = append(errs, errors.New("ignition_config: exactly one of file or content must be set"))
+	} else if c.File != "" {
+		if _, err := os.Stat(c.File); err != nil {
+			errs = append(errs, fmt.Errorf("ignition_config: file %s is not accessible: %s", c.File, err))
+		}
+	}
+
+	return errs
+}
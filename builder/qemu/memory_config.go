@@ -0,0 +1,172 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var memorySizeRe = regexp.MustCompile(`^(\d+)([kmgt]?)$`)
+
+var memoryBackends = map[string]bool{
+	"ram":   true,
+	"file":  true,
+	"memfd": true,
+}
+
+// defaultHugepagesPath is where hugetlbfs is usually mounted.
+const defaultHugepagesPath = "/dev/hugepages"
+
+// A `numa_node` block adds a NUMA node to the VM, with a part of the memory
+// and of the CPUs. The memory of all the nodes must add up to `memory`, and
+// each CPU must be assigned to exactly one node. The memory of each node is
+// allocated from `memory_backend`, which defaults to `ram` with NUMA nodes.
+//
+// In HCL2:
+// ```hcl
+//   cpus   = 4
+//   memory = 4096
+//
+//   numa_node {
+//     cpus   = "0-1"
+//     memory = "2G"
+//   }
+//
+//   numa_node {
+//     cpus   = "2-3"
+//     memory = "2G"
+//   }
+// ```
+type NUMANodeConfig struct {
+	// The CPUs of the node, as a comma separated list of CPU indexes or
+	// ranges, like `0-1` or `0,2-3`. CPU indexes start at 0.
+	CPUs string `mapstructure:"cpus" required:"true"`
+	// The amount of memory of the node, with the same units as `memory`.
+	Memory string `mapstructure:"memory" required:"true"`
+	// The host NUMA nodes to bind the memory of the node to, like `0` or
+	// `0-1`. Unset by default, the memory is allocated from any host node.
+	HostNodes string `mapstructure:"host_nodes" required:"false"`
+
+	// The memory of the node in megabytes, set by Prepare.
+	memoryMiB int
+}
+
+func (c *NUMANodeConfig) Prepare(cpuCount int) []error {
+	var errs []error
+
+	if _, err := parseCPUList(c.CPUs, cpuCount); err != nil {
+		errs = append(errs, fmt.Errorf("numa_node cpus %q is invalid: %s", c.CPUs, err))
+	}
+	if memory, err := parseMemorySize(c.Memory); err != nil {
+		errs = append(errs, fmt.Errorf("numa_node memory %s", err))
+	} else if memory < 1 {
+		errs = append(errs, errors.New("numa_node memory must be positive"))
+	} else {
+		c.Memory = fmt.Sprintf("%dM", memory)
+		c.memoryMiB = memory
+	}
+	if c.HostNodes != "" {
+		if _, err := parseCPUList(c.HostNodes, 0); err != nil {
+			errs = append(errs, fmt.Errorf("numa_node host_nodes %q is invalid: %s", c.HostNodes, err))
+		}
+	}
+
+	return errs
+}
+
+// parseCPUList parses a comma separated list of indexes or ranges, like
+// 0,2-3, and returns the indexes. When max is not 0, the indexes must be
+// lower than max.
+func parseCPUList(list string, max int) ([]int, error) {
+	if list == "" {
+		return nil, errors.New("the list is empty")
+	}
+
+	var indexes []int
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("%q is not a valid index", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("%q is not a valid range", part)
+			}
+		}
+		if max != 0 && last >= max {
+			return nil, fmt.Errorf("index %d is out of range, there are %d CPUs", last, max)
+		}
+		for i := first; i <= last; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// parseMemorySize parses a memory size with an optional K, M, G or T unit
+// suffix, in megabytes without suffix, and returns it in megabytes.
+func parseMemorySize(size string) (int, error) {
+	m := memorySizeRe.FindStringSubmatch(strings.ToLower(size))
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a valid size, use a number with an optional K, M, G or T unit", size)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid size: %s", size, err)
+	}
+
+	switch m[2] {
+	case "k":
+		if n%1024 != 0 {
+			return 0, fmt.Errorf("%q is not a whole number of megabytes", size)
+		}
+		n /= 1024
+	case "g":
+		n *= 1024
+	case "t":
+		n *= 1024 * 1024
+	}
+	return n, nil
+}
+
+// numaArgument returns the -numa argument of the node.
+func (c *NUMANodeConfig) numaArgument(nodeID int, memdev string) string {
+	arg := fmt.Sprintf("node,nodeid=%d", nodeID)
+	for _, cpus := range strings.Split(c.CPUs, ",") {
+		arg += ",cpus=" + cpus
+	}
+	return arg + ",memdev=" + memdev
+}
+
+// memoryBackendArgument returns the -object argument of a memory backend of
+// size megabytes, bound to the hostNodes when set.
+func (c *Config) memoryBackendArgument(id string, size int, hostNodes string) string {
+	arg := fmt.Sprintf("memory-backend-%s,id=%s,size=%dM", c.MemoryBackend, id, size)
+	switch c.MemoryBackend {
+	case "file":
+		arg += ",mem-path=" + qemuEscape(c.MemoryBackendPath)
+	case "memfd":
+		if c.MemoryHugepages {
+			arg += ",hugetlb=on"
+		}
+	}
+	if c.MemoryShare {
+		arg += ",share=on"
+	}
+	if c.MemoryPrealloc {
+		arg += ",prealloc=on"
+	}
+	if hostNodes != "" {
+		for _, nodes := range strings.Split(hostNodes, ",") {
+			arg += ",host-nodes=" + nodes
+		}
+		arg += ",policy=bind"
+	}
+	return arg
+}
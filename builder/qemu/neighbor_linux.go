@@ -0,0 +1,136 @@
+//go:build linux
+// +build linux
+
+package qemu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Neighbor message attributes and states, see
+// https://github.com/torvalds/linux/blob/v5.4/include/uapi/linux/neighbour.h
+const (
+	ndaDst    = 1 // NDA_DST
+	ndaLLAddr = 2 // NDA_LLADDR
+
+	nudIncomplete = 0x01 // NUD_INCOMPLETE
+	nudFailed     = 0x20 // NUD_FAILED
+	nudNoArp      = 0x40 // NUD_NOARP
+
+	// sizeof(struct ndmsg)
+	ndMsgLen = 12
+)
+
+// nativeEndian is the byte order of netlink messages, which use the host one.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// getNeighborIPv6Address looks up the IPv6 address of macAddress in the
+// neighbor table of the device, like `ip -6 neigh show dev <device>` does.
+// Global addresses are preferred over link-local ones, which are returned
+// with the device as zone.
+func getNeighborIPv6Address(device string, macAddress string) (string, error) {
+	hwAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse MAC address %s: %w", macAddress, err)
+	}
+
+	ifindex := 0
+	if device != "" {
+		iface, err := net.InterfaceByName(device)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the %s interface: %w", device, err)
+		}
+		ifindex = iface.Index
+	}
+
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return "", fmt.Errorf("failed to dump the neighbor table: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the neighbor table: %w", err)
+	}
+
+	var global, linkLocal []string
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWNEIGH || len(msg.Data) < ndMsgLen {
+			continue
+		}
+
+		family := msg.Data[0]
+		index := int(int32(nativeEndian.Uint32(msg.Data[4:8])))
+		state := nativeEndian.Uint16(msg.Data[8:10])
+		if family != syscall.AF_INET6 || (ifindex != 0 && index != ifindex) {
+			continue
+		}
+		if state&(nudIncomplete|nudFailed|nudNoArp) != 0 {
+			continue
+		}
+
+		ip, lladdr := parseNeighborAttributes(msg.Data[ndMsgLen:])
+		if ip == nil || lladdr.String() != hwAddr.String() {
+			continue
+		}
+
+		if !ip.IsLinkLocalUnicast() {
+			global = append(global, ip.String())
+			continue
+		}
+		iface, err := net.InterfaceByIndex(index)
+		if err != nil {
+			continue
+		}
+		linkLocal = append(linkLocal, fmt.Sprintf("%s%%%s", ip, iface.Name))
+	}
+
+	if len(global) > 0 {
+		return global[0], nil
+	}
+	if len(linkLocal) > 0 {
+		return linkLocal[0], nil
+	}
+	return "", fmt.Errorf("could not find %s", macAddress)
+}
+
+// parseNeighborAttributes returns the NDA_DST and NDA_LLADDR attributes of a
+// neighbor message.
+func parseNeighborAttributes(b []byte) (net.IP, net.HardwareAddr) {
+	var ip net.IP
+	var lladdr net.HardwareAddr
+
+	for len(b) >= syscall.SizeofRtAttr {
+		attrLen := int(nativeEndian.Uint16(b[0:2]))
+		attrType := nativeEndian.Uint16(b[2:4])
+		if attrLen < syscall.SizeofRtAttr || attrLen > len(b) {
+			break
+		}
+
+		value := b[syscall.SizeofRtAttr:attrLen]
+		switch attrType {
+		case ndaDst:
+			ip = net.IP(value)
+		case ndaLLAddr:
+			lladdr = net.HardwareAddr(value)
+		}
+
+		// Attributes are aligned on 4 bytes
+		attrLen = (attrLen + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if attrLen > len(b) {
+			break
+		}
+		b = b[attrLen:]
+	}
+
+	return ip, lladdr
+}
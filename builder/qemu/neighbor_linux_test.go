@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package qemu
+
+import (
+	"testing"
+)
+
+func TestParseNeighborAttributes(t *testing.T) {
+	attr := func(attrType uint16, value []byte) []byte {
+		b := make([]byte, 4, 4+len(value)+3)
+		nativeEndian.PutUint16(b[0:2], uint16(4+len(value)))
+		nativeEndian.PutUint16(b[2:4], attrType)
+		b = append(b, value...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	data := attr(ndaDst, []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10})
+	data = append(data, attr(ndaLLAddr, []byte{0x52, 0x54, 0x00, 0x12, 0x34, 0x56})...)
+	data = append(data, attr(8, []byte{1, 2, 3, 4})...)
+
+	ip, lladdr := parseNeighborAttributes(data)
+	if ip.String() != "2001:db8::10" {
+		t.Errorf("bad IP address: %s", ip)
+	}
+	if lladdr.String() != "52:54:00:12:34:56" {
+		t.Errorf("bad link layer address: %s", lladdr)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package qemu
+
+import "errors"
+
+func getNeighborIPv6Address(device string, macAddress string) (string, error) {
+	return "", errors.New("the neighbor table can only be read on Linux")
+}
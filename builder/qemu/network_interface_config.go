@@ -0,0 +1,187 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+var netBackends = map[string]bool{
+	"user":   true,
+	"bridge": true,
+	"tap":    true,
+	"socket": true,
+	"vde":    true,
+	"passt":  true,
+}
+
+var netSocketModes = map[string]bool{
+	"listen":  true,
+	"connect": true,
+	"mcast":   true,
+}
+
+// A `network_interface` block attaches a network interface to the VM. The
+// communicator connects to the guest through the interface with
+// `communicator` set, or the first one. How the guest address is found
+// depends on the backend of this interface:
+//
+//   - `user`: the communicator port is forwarded from the host, see
+//     `host_port_min` and `host_port_max`.
+//   - `bridge` and `tap`: the guest address is looked up from the MAC address
+//     of the interface, from the `guest_address_sources`, or set with
+//     `guest_address`.
+//   - `passt`: as with `user`, the communicator port is forwarded from the
+//     host. The guest gets the `10.0.2.15` address, so it is known up front.
+//   - `socket` and `vde`: the guest address can't be found, `ssh_host` or
+//     `winrm_host` must be set.
+//
+// The interfaces are attached in order, with the netdev ids `user.0`,
+// `user.1`, and so on, which can be referenced in `qemuargs`.
+//
+// In HCL2:
+// ```hcl
+//   network_interface {
+//     backend      = "user"
+//     communicator = true
+//   }
+//
+//   network_interface {
+//     backend     = "bridge"
+//     bridge      = "virbr0"
+//     model       = "e1000"
+//     mac_address = "52:54:00:12:34:56"
+//   }
+// ```
+type NetworkInterfaceConfig struct {
+	// The QEMU network backend. Allowed values are `user`, `bridge`, `tap`,
+	// `socket`, `vde` and `passt`. Defaults to `user`.
+	//
+	// With `passt`, a [passt](https://passt.top) process is started by Packer
+	// for the interface and connected to QEMU with `-netdev stream`, which
+	// requires QEMU 7.2 or later. It provides faster networking than `user`
+	// without privileges, for example in rootless containers. The `passt`
+	// binary must be in the `PATH`, and the guest reaches the host loopback
+	// at `10.0.2.2`. passt configures IPv6 by itself, and forwards only the
+	// communicator port: `net_ipv6` and `port_forward` can't be used with it.
+	Backend string `mapstructure:"backend" required:"false"`
+	// The driver to use for the network interface, as for `net_device`.
+	// Defaults to `net_device`.
+	Model string `mapstructure:"model" required:"false"`
+	// The MAC address of the interface. Defaults to an address in the QEMU
+	// `52:54:00` prefix generated from the build name, `vm_name` and the
+	// position of the interface, so that it is the same for every build of
+	// the VM. Concurrent builds of the same source share the generated
+	// address: on the `bridge`, `tap`, `socket` and `vde` backends, set it to
+	// run them at once on the same network.
+	MACAddress string `mapstructure:"mac_address" required:"false"`
+	// The communicator connects to the guest through this interface. Only one
+	// interface can be set. Defaults to `true` for the first interface when
+	// none is set.
+	Communicator bool `mapstructure:"communicator" required:"false"`
+	// The bridge to connect the interface to, with the `bridge` backend. It
+	// must already exist, as for `net_bridge`.
+	Bridge string `mapstructure:"bridge" required:"false"`
+	// The name of the tap interface of the host, with the `tap` backend. The
+	// interface is not configured by Packer, no script is run.
+	Ifname string `mapstructure:"ifname" required:"false"`
+	// How to use `socket_address` with the `socket` backend. Allowed values
+	// are `listen`, `connect` and `mcast`. Defaults to `connect`.
+	SocketMode string `mapstructure:"socket_mode" required:"false"`
+	// The `host:port` address of the socket, with the `socket` backend.
+	SocketAddress string `mapstructure:"socket_address" required:"false"`
+	// The path to the VDE switch socket, with the `vde` backend. By default
+	// QEMU uses the default switch.
+	VDESocket string `mapstructure:"vde_socket" required:"false"`
+}
+
+func (c *NetworkInterfaceConfig) Prepare(defaultModel string) []error {
+	var errs []error
+
+	if c.Backend == "" {
+		c.Backend = "user"
+	}
+	if c.Model == "" {
+		c.Model = defaultModel
+	}
+
+	if !netBackends[c.Backend] {
+		errs = append(errs, fmt.Errorf("network_interface backend %q is not supported, only user, bridge, tap, socket, vde and passt are allowed", c.Backend))
+	}
+
+	if c.MACAddress != "" {
+		if err := checkMACAddress(c.MACAddress); err != nil {
+			errs = append(errs, fmt.Errorf("network_interface mac_address %q is invalid: %s", c.MACAddress, err))
+		}
+	}
+
+	switch c.Backend {
+	case "bridge":
+		if c.Bridge == "" {
+			errs = append(errs, errors.New("network_interface bridge must be set with the bridge backend"))
+		}
+	case "tap":
+		if c.Ifname == "" {
+			errs = append(errs, errors.New("network_interface ifname must be set with the tap backend"))
+		}
+	case "socket":
+		if c.SocketMode == "" {
+			c.SocketMode = "connect"
+		}
+		if !netSocketModes[c.SocketMode] {
+			errs = append(errs, fmt.Errorf("network_interface socket_mode %q is not supported, only listen, connect and mcast are allowed", c.SocketMode))
+		}
+		if c.SocketAddress == "" {
+			errs = append(errs, errors.New("network_interface socket_address must be set with the socket backend"))
+		}
+	}
+
+	return errs
+}
+
+// netdevID returns the netdev id of the network interface at index.
+func netdevID(index int) string {
+	return fmt.Sprintf("user.%d", index)
+}
+
+// netdevArgument returns the -netdev argument of the interface, without the
+// options specific to the user backend.
+func (c *NetworkInterfaceConfig) netdevArgument(id string) string {
+	switch c.Backend {
+	case "bridge":
+		return fmt.Sprintf("bridge,id=%s,br=%s", id, c.Bridge)
+	case "tap":
+		return fmt.Sprintf("tap,id=%s,ifname=%s,script=no,downscript=no", id, c.Ifname)
+	case "socket":
+		return fmt.Sprintf("socket,id=%s,%s=%s", id, c.SocketMode, c.SocketAddress)
+	case "vde":
+		if c.VDESocket != "" {
+			return fmt.Sprintf("vde,id=%s,sock=%s", id, qemuEscape(c.VDESocket))
+		}
+		return fmt.Sprintf("vde,id=%s", id)
+	}
+	return fmt.Sprintf("user,id=%s", id)
+}
+
+// deviceArgument returns the -device argument of the interface.
+func (c *NetworkInterfaceConfig) deviceArgument(id string) string {
+	device := fmt.Sprintf("%s,netdev=%s", c.Model, id)
+	if c.MACAddress != "" {
+		device = fmt.Sprintf("%s,mac=%s", device, c.MACAddress)
+	}
+	return device
+}
+
+// checkMACAddress returns an error unless mac is a 6-byte MAC address, QEMU
+// not accepting the EUI-64 and InfiniBand addresses net.ParseMAC also parses.
+func checkMACAddress(mac string) error {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+	if len(hwAddr) != 6 {
+		return fmt.Errorf("only 6-byte addresses are supported, not %d-byte ones", len(hwAddr))
+	}
+	return nil
+}
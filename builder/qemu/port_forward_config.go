@@ -0,0 +1,88 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var portForwardNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+var portForwardProtocols = map[string]bool{
+	"tcp": true,
+	"udp": true,
+}
+
+// A `port_forward` block forwards a port of the host to a port of the guest,
+// in addition to the communicator port, with the `user` network backend.
+// Like the communicator port, the host port is picked in a range and locked
+// for the duration of the build. It is made available to provisioners as the
+// `PortForward_<name>` build variable, for example
+// ``{{ build `PortForward_web` }}`` in JSON or `${build.PortForward_web}` in
+// HCL2.
+//
+// In HCL2:
+// ```hcl
+//   port_forward {
+//     name       = "web"
+//     guest_port = 80
+//   }
+// ```
+type PortForwardConfig struct {
+	// The name of the forward, made of letters, digits and underscores. It
+	// must be unique.
+	Name string `mapstructure:"name" required:"true"`
+	// The port of the guest to forward to.
+	GuestPort int `mapstructure:"guest_port" required:"true"`
+	// The protocol to forward, `tcp` or `udp`. Defaults to `tcp`. The host
+	// port is always checked for availability over TCP.
+	Protocol string `mapstructure:"protocol" required:"false"`
+	// The host port to forward from. When unset, a port is picked between
+	// `host_port_min` and `host_port_max`.
+	HostPort int `mapstructure:"host_port" required:"false"`
+	// The minimum host port to forward from. Defaults to the `host_port_min`
+	// of the communicator.
+	HostPortMin int `mapstructure:"host_port_min" required:"false"`
+	// The maximum host port to forward from. Defaults to the `host_port_max`
+	// of the communicator.
+	HostPortMax int `mapstructure:"host_port_max" required:"false"`
+}
+
+func (c *PortForwardConfig) Prepare(defaultHostPortMin, defaultHostPortMax int) []error {
+	var errs []error
+
+	if c.Protocol == "" {
+		c.Protocol = "tcp"
+	}
+	if c.HostPort != 0 {
+		c.HostPortMin = c.HostPort
+		c.HostPortMax = c.HostPort
+	}
+	if c.HostPortMin == 0 {
+		c.HostPortMin = defaultHostPortMin
+	}
+	if c.HostPortMax == 0 {
+		c.HostPortMax = defaultHostPortMax
+	}
+
+	if !portForwardNameRe.MatchString(c.Name) {
+		errs = append(errs, fmt.Errorf("port_forward name %q must only contain letters, digits and underscores", c.Name))
+	}
+	if c.GuestPort < 1 || c.GuestPort > 65535 {
+		errs = append(errs, fmt.Errorf("port_forward %q: guest_port must be a valid port", c.Name))
+	}
+	if !portForwardProtocols[c.Protocol] {
+		errs = append(errs, fmt.Errorf("port_forward %q: protocol %q is not supported, only tcp and udp are allowed", c.Name, c.Protocol))
+	}
+	if c.HostPortMin < 1 || c.HostPortMax > 65535 || c.HostPortMin > c.HostPortMax {
+		errs = append(errs, fmt.Errorf("port_forward %q: the host port range %d-%d is invalid", c.Name, c.HostPortMin, c.HostPortMax))
+	}
+
+	return errs
+}
+
+// generatedDataName returns the name of the build variable holding the host
+// port of the forward.
+func (c *PortForwardConfig) generatedDataName() string {
+	return "PortForward_" + c.Name
+}
@@ -0,0 +1,104 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var guidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// secureBootFirmwares are the usual locations of the OVMF firmware built
+// with Secure Boot and SMM support, with a variable store in which the
+// Microsoft keys are enrolled, by distribution.
+var secureBootFirmwares = []struct {
+	Code string
+	Vars string
+}{
+	// Debian, Ubuntu
+	{"/usr/share/OVMF/OVMF_CODE_4M.secboot.fd", "/usr/share/OVMF/OVMF_VARS_4M.ms.fd"},
+	{"/usr/share/OVMF/OVMF_CODE.secboot.fd", "/usr/share/OVMF/OVMF_VARS.ms.fd"},
+	// Fedora, RHEL
+	{"/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd", "/usr/share/edk2/ovmf/OVMF_VARS.secboot.fd"},
+	// openSUSE
+	{"/usr/share/qemu/ovmf-x86_64-smm-ms-code.bin", "/usr/share/qemu/ovmf-x86_64-smm-ms-vars.bin"},
+}
+
+// A `secure_boot_keys` block enrolls custom keys in the UEFI variable store
+// before the VM boots, with `secure_boot`, so that images signed with them
+// can be booted. The keys are enrolled with the `virt-fw-vars` tool of
+// [virt-firmware](https://gitlab.com/kraxel/virt-firmware), which must be in
+// the `PATH`. The platform key replaces the one of `efi_firmware_vars`, while
+// the other certificates are added to those already enrolled.
+//
+// In HCL2:
+// ```hcl
+//   secure_boot = true
+//
+//   secure_boot_keys {
+//     pk  = "keys/pk.pem"
+//     kek = ["keys/kek.pem"]
+//     db  = ["keys/db.pem"]
+//   }
+// ```
+type SecureBootKeysConfig struct {
+	// The path to the PEM certificate of the platform key.
+	PK string `mapstructure:"pk" required:"true"`
+	// The paths to the PEM certificates to add to the key exchange keys.
+	KEK []string `mapstructure:"kek" required:"false"`
+	// The paths to the PEM certificates to add to the signature database.
+	DB []string `mapstructure:"db" required:"false"`
+	// The GUID identifying the owner of the certificates. Defaults to a GUID
+	// generated from `vm_name`.
+	OwnerGUID string `mapstructure:"owner_guid" required:"false"`
+}
+
+func (c *SecureBootKeysConfig) Prepare(vmName string) []error {
+	var errs []error
+
+	if c.OwnerGUID == "" {
+		c.OwnerGUID = generateGUID(vmName)
+	} else if !guidRe.MatchString(c.OwnerGUID) {
+		errs = append(errs, fmt.Errorf("secure_boot_keys owner_guid %q is not a valid GUID", c.OwnerGUID))
+	}
+
+	if c.PK == "" {
+		errs = append(errs, errors.New("secure_boot_keys pk must be set"))
+	}
+	for _, path := range append(append([]string{c.PK}, c.KEK...), c.DB...) {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("secure_boot_keys certificate %s is not accessible: %s", path, err))
+		}
+	}
+
+	return errs
+}
+
+// virtFwVarsArgs returns the arguments of virt-fw-vars enrolling the keys in
+// the variable store at input, written to output.
+func (c *SecureBootKeysConfig) virtFwVarsArgs(input string, output string) []string {
+	args := []string{"--input", input, "--output", output, "--set-pk", c.OwnerGUID, c.PK}
+	for _, kek := range c.KEK {
+		args = append(args, "--add-kek", c.OwnerGUID, kek)
+	}
+	for _, db := range c.DB {
+		args = append(args, "--add-db", c.OwnerGUID, db)
+	}
+	return append(args, "--secure-boot")
+}
+
+// generateGUID returns a GUID generated from name, so that it is the same for
+// every build.
+func generateGUID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	// Set the version 4 and the RFC 4122 variant bits
+	sum[6] = sum[6]&0x0f | 0x40
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,154 @@
+//go:generate packer-sdc struct-markdown
+package qemu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var sharedFolderNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// The tags are passed to QEMU unescaped, in the -device arguments.
+var sharedFolderTagRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var sharedFolderTypes = map[string]bool{
+	"virtiofs": true,
+	"9p":       true,
+}
+
+var sharedFolderSecurityModels = map[string]bool{
+	"mapped-xattr": true,
+	"mapped-file":  true,
+	"passthrough":  true,
+	"none":         true,
+}
+
+// The maximum length of the mount tags, 9p allowing less than virtio-fs.
+var sharedFolderTagMaxLengths = map[string]int{
+	"virtiofs": 36,
+	"9p":       31,
+}
+
+// A `shared_folder` block exposes a directory of the host to the guest,
+// which is faster than uploading large files with the `file` provisioner.
+// The guest mounts the folder from its tag, for example with
+// `mount -t virtiofs <tag> /mnt` or
+// `mount -t 9p -o trans=virtio,version=9p2000.L <tag> /mnt`.
+//
+// With `virtiofs`, Packer starts a `virtiofsd` process for each folder, and
+// the memory of the VM is shared with it: `memory_backend` defaults to
+// `memfd` and `memory_share` is enabled. The tag is available to
+// provisioners as the `SharedFolder_<name>` build variable, for example
+// ``{{ build `SharedFolder_inputs` }}`` in JSON or
+// `${build.SharedFolder_inputs}` in HCL2.
+//
+// In HCL2:
+// ```hcl
+//   shared_folder {
+//     name      = "inputs"
+//     host_path = "./inputs"
+//     read_only = true
+//   }
+// ```
+type SharedFolderConfig struct {
+	// The name of the folder, made of letters, digits and underscores. It
+	// must be unique.
+	Name string `mapstructure:"name" required:"true"`
+	// The path to the directory of the host to share.
+	HostPath string `mapstructure:"host_path" required:"true"`
+	// The tag the guest mounts the folder from, made of letters, digits,
+	// `_`, `.` and `-`. It must be unique. Defaults to `name`.
+	Tag string `mapstructure:"tag" required:"false"`
+	// How the folder is shared, `virtiofs` or `9p`. `virtiofs` is faster and
+	// requires a Linux host and the Rust
+	// [virtiofsd](https://gitlab.com/virtio-fs/virtiofsd), in the `PATH` or
+	// in `/usr/libexec`: the legacy virtiofsd of QEMU before 8.0 is not
+	// supported. `9p` is built into QEMU. The folders are set with the
+	// `-device` argument, and the `-chardev`, `-object` and `-machine`
+	// arguments with `virtiofs` or `-fsdev` with `9p`, which can't be set in
+	// `qemuargs` then. Defaults to `virtiofs`.
+	Type string `mapstructure:"type" required:"false"`
+	// Share the folder read-only. Defaults to `false`.
+	ReadOnly bool `mapstructure:"read_only" required:"false"`
+	// How the ownership and permissions of the files are stored with `9p`.
+	// Allowed values are `mapped-xattr`, `mapped-file`, `passthrough` and
+	// `none`, see the `-fsdev` option of QEMU. Defaults to `mapped-xattr`.
+	SecurityModel string `mapstructure:"security_model" required:"false"`
+}
+
+func (c *SharedFolderConfig) Prepare() []error {
+	var errs []error
+
+	if c.Type == "" {
+		c.Type = "virtiofs"
+	}
+	if c.Tag == "" {
+		c.Tag = c.Name
+	}
+	if c.SecurityModel == "" && c.Type == "9p" {
+		c.SecurityModel = "mapped-xattr"
+	}
+
+	if !sharedFolderNameRe.MatchString(c.Name) {
+		errs = append(errs, fmt.Errorf("shared_folder name %q must only contain letters, digits and underscores", c.Name))
+	}
+	if !sharedFolderTagRe.MatchString(c.Tag) {
+		errs = append(errs, fmt.Errorf("shared_folder %q: tag %q must only contain letters, digits, _, . and -", c.Name, c.Tag))
+	}
+	if !sharedFolderTypes[c.Type] {
+		errs = append(errs, fmt.Errorf("shared_folder %q: type %q is not supported, only virtiofs and 9p are allowed", c.Name, c.Type))
+	} else if len(c.Tag) > sharedFolderTagMaxLengths[c.Type] {
+		errs = append(errs, fmt.Errorf("shared_folder %q: tag %q must be at most %d characters long with %s", c.Name, c.Tag, sharedFolderTagMaxLengths[c.Type], c.Type))
+	}
+	if c.SecurityModel != "" {
+		if c.Type != "9p" {
+			errs = append(errs, fmt.Errorf("shared_folder %q: security_model can only be used with 9p", c.Name))
+		} else if !sharedFolderSecurityModels[c.SecurityModel] {
+			errs = append(errs, fmt.Errorf("shared_folder %q: security_model %q is not supported", c.Name, c.SecurityModel))
+		}
+	}
+
+	if c.HostPath == "" {
+		errs = append(errs, fmt.Errorf("shared_folder %q: host_path must be set", c.Name))
+	} else if info, err := os.Stat(c.HostPath); err != nil {
+		errs = append(errs, fmt.Errorf("shared_folder %q: host_path %s is not accessible: %s", c.Name, c.HostPath, err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("shared_folder %q: host_path %s is not a directory", c.Name, c.HostPath))
+	} else if abs, err := filepath.Abs(c.HostPath); err == nil {
+		// virtiofsd and QEMU may not run in the current directory
+		c.HostPath = abs
+	}
+
+	return errs
+}
+
+// fsdevArgument returns the -fsdev argument of a 9p folder.
+func (c *SharedFolderConfig) fsdevArgument(id string) string {
+	arg := fmt.Sprintf("local,id=%s,path=%s,security_model=%s", id, qemuEscape(c.HostPath), c.SecurityModel)
+	if c.ReadOnly {
+		arg += ",readonly=on"
+	}
+	return arg
+}
+
+// virtiofsdArgs returns the arguments of virtiofsd serving the folder on
+// socketPath.
+func (c *SharedFolderConfig) virtiofsdArgs(socketPath string, unprivileged bool) []string {
+	args := []string{"--socket-path", socketPath, "--shared-dir", c.HostPath, "--cache", "auto"}
+	if c.ReadOnly {
+		args = append(args, "--readonly")
+	}
+	// The default namespace sandbox requires privileges
+	if unprivileged {
+		args = append(args, "--sandbox", "none")
+	}
+	return args
+}
+
+// generatedDataName returns the name of the build variable holding the tag
+// of the folder.
+func (c *SharedFolderConfig) generatedDataName() string {
+	return "SharedFolder_" + c.Name
+}
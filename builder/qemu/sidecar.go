@@ -0,0 +1,122 @@
+package qemu
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// sidecar is a helper process run alongside the VM, such as passt. Its output
+// is logged, and an unexpected exit is logged as well since the VM usually
+// can't work properly without it.
+type sidecar struct {
+	Name string
+	Path string
+	Args []string
+
+	cmd      *exec.Cmd
+	done     chan struct{}
+	lock     sync.Mutex
+	stopping bool
+	err      error
+}
+
+// Start starts the process. It fails if the process exits right away.
+func (s *sidecar) Start() error {
+	stdout_r, stdout_w := io.Pipe()
+	stderr_r, stderr_w := io.Pipe()
+
+	log.Printf("Executing %s: %s %#v", s.Name, s.Path, s.Args)
+	s.cmd = exec.Command(s.Path, s.Args...)
+	s.cmd.Stdout = stdout_w
+	s.cmd.Stderr = stderr_w
+
+	if err := s.cmd.Start(); err != nil {
+		return fmt.Errorf("Error starting %s: %s", s.Name, err)
+	}
+
+	go logReader(s.Name+" stdout", stdout_r)
+	go logReader(s.Name+" stderr", stderr_r)
+
+	log.Printf("Started %s. Pid: %d", s.Name, s.cmd.Process.Pid)
+
+	s.done = make(chan struct{})
+	go func() {
+		defer stderr_w.Close()
+		defer stdout_w.Close()
+
+		err := s.cmd.Wait()
+
+		s.lock.Lock()
+		s.err = err
+		if !s.stopping {
+			log.Printf("%s exited unexpectedly: %v", s.Name, err)
+		}
+		s.lock.Unlock()
+		close(s.done)
+	}()
+
+	// Wait a bit for an early fail, like for Qemu
+	select {
+	case <-s.done:
+		return fmt.Errorf("%s failed to start: %v. Please run with PACKER_LOG=1 to get more info.", s.Name, s.err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	return nil
+}
+
+// WaitForFile waits for the process to create the file at path, like a
+// socket it listens on.
+func (s *sidecar) WaitForFile(path string, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-s.done:
+			return fmt.Errorf("%s exited before creating %s: %v", s.Name, path, s.err)
+		case <-deadline:
+			return fmt.Errorf("timeout waiting for %s to create %s", s.Name, path)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop terminates the process, killing it if it doesn't exit in time.
+func (s *sidecar) Stop() error {
+	if s.cmd == nil || s.cmd.Process == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	s.stopping = true
+	s.lock.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	if err := s.cmd.Process.Signal(os.Interrupt); err != nil {
+		log.Printf("Error interrupting %s: %s", s.Name, err)
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(5 * time.Second):
+	}
+
+	log.Printf("%s didn't exit in time, killing it", s.Name)
+	if err := s.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-s.done
+	return nil
+}
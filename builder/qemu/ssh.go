@@ -2,6 +2,8 @@ package qemu
 
 import (
 	"log"
+	"net"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 )
@@ -10,17 +12,30 @@ func commHost(host string) func(multistep.StateBag) (string, error) {
 	return func(state multistep.StateBag) (string, error) {
 		if host != "" {
 			log.Printf("Using host value: %s", host)
-			return host, nil
+			return bracketIPv6(host), nil
 		}
 
 		if guestAddress, ok := state.Get("guestAddress").(string); ok {
-			return guestAddress, nil
+			return bracketIPv6(guestAddress), nil
 		}
 
 		return "127.0.0.1", nil
 	}
 }
 
+// bracketIPv6 encloses IPv6 literals in brackets, the communicators joining
+// the host and the port with a colon.
+func bracketIPv6(host string) string {
+	if strings.HasPrefix(host, "[") {
+		return host
+	}
+	// Link-local addresses are scoped to an interface, like fe80::1%virbr0
+	if ip := net.ParseIP(strings.SplitN(host, "%", 2)[0]); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
+
 func commPort(state multistep.StateBag) (int, error) {
 	commHostPort, ok := state.Get("commHostPort").(int)
 	if !ok {
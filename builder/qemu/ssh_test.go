@@ -0,0 +1,40 @@
+package qemu
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestCommHost(t *testing.T) {
+	type testCase struct {
+		Host         string
+		GuestAddress string
+		Expected     string
+	}
+
+	testCases := []testCase{
+		{"", "", "127.0.0.1"},
+		{"", "192.168.122.10", "192.168.122.10"},
+		{"", "2001:db8::10", "[2001:db8::10]"},
+		{"", "fe80::10%virbr0", "[fe80::10%virbr0]"},
+		{"2001:db8::20", "2001:db8::10", "[2001:db8::20]"},
+		{"[2001:db8::20]", "", "[2001:db8::20]"},
+		{"build.example.com", "", "build.example.com"},
+	}
+
+	for _, tc := range testCases {
+		state := new(multistep.BasicStateBag)
+		if tc.GuestAddress != "" {
+			state.Put("guestAddress", tc.GuestAddress)
+		}
+
+		host, err := commHost(tc.Host)(state)
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+		if host != tc.Expected {
+			t.Errorf("host %q, guest address %q: got %q, expected %q", tc.Host, tc.GuestAddress, host, tc.Expected)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/digitalocean/go-qemu/qmp"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // This step configures the VM to enable the QMP listener.
@@ -19,6 +20,7 @@ import (
 //   ui     packersdk.Ui
 //
 // Produces:
+//   qmp_monitor *qmp.SocketMonitor - The connected QMP monitor.
 type stepConfigureQMP struct {
 	monitor       *qmp.SocketMonitor
 	QMPSocketPath string
@@ -72,8 +74,22 @@ func (s *stepConfigureQMP) Run(ctx context.Context, state multistep.StateBag) mu
 		log.Printf("QMP Command: %s\nResult: %s", cmd, result)
 	}
 
+	if spicePassword, _ := state.Get("spice_password").(string); spicePassword != "" {
+		cmd = []byte(fmt.Sprintf("{ \"execute\": \"set_password\", \"arguments\": { \"protocol\": \"spice\", \"password\": \"%s\" } }",
+			spicePassword))
+		result, err = s.monitor.Run(cmd)
+		if err != nil {
+			err := fmt.Errorf("Error setting the SPICE password: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		log.Printf("QMP Command: %s\nResult: %s", cmd, result)
+	}
+
 	// make the qmp_monitor available to other steps.
 	state.Put("qmp_monitor", s.monitor)
+	(&packerbuilderdata.GeneratedData{State: state}).Put("QMPSocket", s.QMPSocketPath)
 
 	return multistep.ActionContinue
 }
@@ -0,0 +1,87 @@
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/net"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+)
+
+// This step configures the VM to enable the SPICE server, with the spice
+// display protocol.
+//
+// Uses:
+//   config *config
+//   ui     packersdk.Ui
+//
+// Produces:
+//   spice_port int - The port that SPICE is configured to listen on.
+//   spice_password string - The SPICE password, empty when not used.
+type stepConfigureSPICE struct {
+	l *net.Listener
+}
+
+func (s *stepConfigureSPICE) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+
+	if config.DisplayProtocol != "spice" {
+		return multistep.ActionContinue
+	}
+
+	// Find an open SPICE port, the port locked for VNC being skipped. Note
+	// that this can still fail later on because we have to release the port
+	// at some point.
+	msg := fmt.Sprintf("Looking for available SPICE port between %d and %d on %s", config.SPICEPortMin, config.SPICEPortMax, config.SPICEBindAddress)
+	ui.Say(msg)
+	log.Print(msg)
+
+	var err error
+	s.l, err = net.ListenRangeConfig{
+		Addr:    config.SPICEBindAddress,
+		Min:     config.SPICEPortMin,
+		Max:     config.SPICEPortMax,
+		Network: "tcp",
+	}.Listen(ctx)
+	if err != nil {
+		err := fmt.Errorf("Error finding SPICE port: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.l.Listener.Close() // free port, but don't unlock lock file
+	spicePort := s.l.Port
+
+	spicePassword := ""
+	if config.SPICEUsePassword {
+		spicePassword = VNCPassword()
+	}
+
+	log.Printf("Found available SPICE port: %d on IP: %s", spicePort, config.SPICEBindAddress)
+	state.Put("spice_port", spicePort)
+	state.Put("spice_password", spicePassword)
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("SPICEAddress", spiceAddress(config.SPICEBindAddress, spicePort))
+	generatedData.Put("SPICEPassword", spicePassword)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepConfigureSPICE) Cleanup(multistep.StateBag) {
+	if s.l != nil {
+		err := s.l.Close()
+		if err != nil {
+			log.Printf("failed to unlock port lockfile: %v", err)
+		}
+	}
+}
+
+// spiceAddress returns the URI remote-viewer connects to.
+func spiceAddress(ip string, port int) string {
+	return fmt.Sprintf("spice://%s:%d", ip, port)
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // This step configures the VM to enable the VNC server.
@@ -18,9 +20,13 @@ import (
 //   ui     packersdk.Ui
 //
 // Produces:
-//   vnc_port int - The port that VNC is configured to listen on.
+//   vnc_port int - The port that VNC is configured to listen on, 0 with
+//     vnc_socket.
+//   vnc_socket_path string - The socket that VNC listens on, with vnc_socket.
+//   vnc_password string - The VNC password, empty when not used.
 type stepConfigureVNC struct {
-	l *net.Listener
+	l          *net.Listener
+	socketPath string
 }
 
 func VNCPassword() string {
@@ -42,6 +48,24 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
+	var vncPassword string
+	if config.VNCUsePassword {
+		vncPassword = VNCPassword()
+	}
+	state.Put("vnc_password", vncPassword)
+
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("VNCPassword", vncPassword)
+
+	if config.VNCSocket {
+		log.Printf("Using the VNC socket: %s", config.VNCSocketPath)
+		s.socketPath = config.VNCSocketPath
+		state.Put("vnc_port", 0)
+		state.Put("vnc_socket_path", config.VNCSocketPath)
+		generatedData.Put("VNCSocket", config.VNCSocketPath)
+		return multistep.ActionContinue
+	}
+
 	// Find an open VNC port. Note that this can still fail later on
 	// because we have to release the port at some point. But this does its
 	// best.
@@ -49,7 +73,6 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	ui.Say(msg)
 	log.Print(msg)
 
-	var vncPassword string
 	var err error
 	s.l, err = net.ListenRangeConfig{
 		Addr:    config.VNCBindAddress,
@@ -66,15 +89,9 @@ func (s *stepConfigureVNC) Run(ctx context.Context, state multistep.StateBag) mu
 	s.l.Listener.Close() // free port, but don't unlock lock file
 	vncPort := s.l.Port
 
-	if config.VNCUsePassword {
-		vncPassword = VNCPassword()
-	} else {
-		vncPassword = ""
-	}
-
 	log.Printf("Found available VNC port: %d on IP: %s", vncPort, config.VNCBindAddress)
 	state.Put("vnc_port", vncPort)
-	state.Put("vnc_password", vncPassword)
+	generatedData.Put("VNCPort", vncPort)
 
 	return multistep.ActionContinue
 }
@@ -86,4 +103,10 @@ func (s *stepConfigureVNC) Cleanup(multistep.StateBag) {
 			log.Printf("failed to unlock port lockfile: %v", err)
 		}
 	}
+	// QEMU leaves the socket behind, which would be part of the artifact.
+	if s.socketPath != "" {
+		if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete the VNC socket file: %s", err)
+		}
+	}
 }
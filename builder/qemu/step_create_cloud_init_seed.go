@@ -0,0 +1,127 @@
+package qemu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step creates the cloud-init NoCloud seed CD.
+//
+// Uses:
+//   ui packersdk.Ui
+//
+// Produces:
+//   cloud_init_seed_path string - The path to the seed CD.
+type stepCreateCloudInitSeed struct {
+	CloudInit *CloudInitConfig
+	Comm      *communicator.Config
+	VMName    string
+
+	createCD *commonsteps.StepCreateCD
+}
+
+func (s *stepCreateCloudInitSeed) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.CloudInit == nil {
+		return multistep.ActionContinue
+	}
+	ui := state.Get("ui").(packersdk.Ui)
+
+	userData := s.CloudInit.UserData
+	if userData == "" {
+		var err error
+		userData, err = cloudInitUserData(s.Comm)
+		if err != nil {
+			err := fmt.Errorf("Error generating cloud-init user-data: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	metaData := s.CloudInit.MetaData
+	if metaData == "" {
+		metaData = fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", s.VMName, s.VMName)
+	}
+
+	content := map[string]string{
+		"user-data": userData,
+		"meta-data": metaData,
+	}
+	if s.CloudInit.NetworkConfig != "" {
+		content["network-config"] = s.CloudInit.NetworkConfig
+	}
+
+	// StepCreateCD always stores its result in cd_path, which is where the
+	// CD created from cd_files and cd_content lives. Move the seed out of the
+	// way and put back any CD created before.
+	previousCDPath, hasPreviousCDPath := state.GetOk("cd_path")
+
+	s.createCD = &commonsteps.StepCreateCD{
+		Content: content,
+		Label:   "cidata",
+	}
+	if action := s.createCD.Run(ctx, state); action != multistep.ActionContinue {
+		err := fmt.Errorf("Error creating the cloud-init seed: %s", state.Get("error"))
+		if s.CloudInit.implied {
+			// Without the seed, the build would only fail once the
+			// communicator times out.
+			err = fmt.Errorf("%s. The seed authorizes the temporary SSH key in the disk_image, "+
+				"set ssh_password or ssh_private_key_file to build without it", err)
+		}
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return action
+	}
+
+	state.Put("cloud_init_seed_path", state.Get("cd_path"))
+	if hasPreviousCDPath {
+		state.Put("cd_path", previousCDPath)
+	} else {
+		state.Remove("cd_path")
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCreateCloudInitSeed) Cleanup(state multistep.StateBag) {
+	if s.createCD != nil {
+		s.createCD.Cleanup(state)
+	}
+}
+
+// cloudInitUserData generates a #cloud-config creating the communicator user.
+// JSON being a subset of YAML, the document is marshaled as JSON to avoid
+// quoting issues with user provided values.
+func cloudInitUserData(comm *communicator.Config) (string, error) {
+	user := map[string]interface{}{
+		"name":  comm.SSHUsername,
+		"sudo":  "ALL=(ALL) NOPASSWD:ALL",
+		"shell": "/bin/bash",
+	}
+	cloudConfig := map[string]interface{}{
+		"users": []interface{}{user},
+	}
+
+	if len(comm.SSHPublicKey) > 0 {
+		user["ssh_authorized_keys"] = []string{strings.TrimSpace(string(comm.SSHPublicKey))}
+	}
+	if comm.SSHPassword != "" {
+		user["lock_passwd"] = false
+		user["plain_text_passwd"] = comm.SSHPassword
+		cloudConfig["ssh_pwauth"] = true
+	}
+
+	b, err := json.MarshalIndent(cloudConfig, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return "#cloud-config\n" + string(b) + "\n", nil
+}
@@ -0,0 +1,75 @@
+package qemu
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepCreateCloudInitSeed_impl(t *testing.T) {
+	var _ multistep.Step = new(stepCreateCloudInitSeed)
+}
+
+func TestStepCreateCloudInitSeed_NoConfig(t *testing.T) {
+	state := testState(t)
+	step := new(stepCreateCloudInitSeed)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("cloud_init_seed_path"); ok {
+		t.Fatal("should NOT have a seed")
+	}
+}
+
+func TestStepCreateCloudInitSeed_ImpliedWithoutTools(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	state := testState(t)
+	step := &stepCreateCloudInitSeed{
+		CloudInit: &CloudInitConfig{implied: true},
+		Comm:      &communicator.Config{},
+		VMName:    "packer",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if err, ok := state.GetOk("error"); !ok || !strings.Contains(err.(error).Error(), "ssh_private_key_file") {
+		t.Fatalf("should have an error about the SSH credentials: %#v", err)
+	}
+	if _, ok := state.GetOk("cloud_init_seed_path"); ok {
+		t.Fatal("should NOT have a seed")
+	}
+}
+
+func TestCloudInitUserData(t *testing.T) {
+	comm := &communicator.Config{
+		SSH: communicator.SSH{
+			SSHUsername:  "packer",
+			SSHPassword:  "secret",
+			SSHPublicKey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI packer\n"),
+		},
+	}
+
+	userData, err := cloudInitUserData(comm)
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !strings.HasPrefix(userData, "#cloud-config\n") {
+		t.Fatalf("user-data should start with #cloud-config: %s", userData)
+	}
+	for _, expected := range []string{
+		`"name": "packer"`,
+		`"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI packer"`,
+		`"plain_text_passwd": "secret"`,
+		`"ssh_pwauth": true`,
+	} {
+		if !strings.Contains(userData, expected) {
+			t.Errorf("user-data should contain %s: %s", expected, userData)
+		}
+	}
+}
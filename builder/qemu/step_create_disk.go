@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // This step creates the virtual disk that will be used as the
@@ -66,6 +68,7 @@ func (s *stepCreateDisk) Run(ctx context.Context, state multistep.StateBag) mult
 
 	// Stash the disk paths so we can retrieve later
 	state.Put("qemu_disk_paths", diskFullPaths)
+	(&packerbuilderdata.GeneratedData{State: state}).Put("DiskPaths", strings.Join(diskFullPaths, ","))
 
 	return multistep.ActionContinue
 }
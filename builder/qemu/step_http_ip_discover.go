@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 // Step to discover the http ip
@@ -19,50 +20,86 @@ func (s *stepHTTPIPDiscover) Run(ctx context.Context, state multistep.StateBag)
 	ui := state.Get("ui").(packersdk.Ui)
 
 	hostIP := ""
+	iface, _ := config.commNetworkInterface()
 
-	if config.NetBridge == "" {
+	switch iface.Backend {
+	case "user":
 		hostIP = "10.0.2.2"
-	} else {
-		bridgeInterface, err := net.InterfaceByName(config.NetBridge)
+		if config.NetworkMode == "restricted" {
+			hostIP = restrictedNetworkGuestIP
+		}
+	case "passt":
+		hostIP = passtGatewayIP
+	case "bridge":
+		bridgeInterface, err := net.InterfaceByName(iface.Bridge)
 		if err != nil {
-			err := fmt.Errorf("Error getting the bridge %s interface: %s", config.NetBridge, err)
+			err := fmt.Errorf("Error getting the bridge %s interface: %s", iface.Bridge, err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 		addrs, err := bridgeInterface.Addrs()
 		if err != nil {
-			err := fmt.Errorf("Error getting the bridge %s interface addresses: %s", config.NetBridge, err)
+			err := fmt.Errorf("Error getting the bridge %s interface addresses: %s", iface.Bridge, err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-			if ip == nil {
-				continue
+		hostIP = bridgeHTTPIP(addrs, config.NetIPv6)
+		if hostIP == "" {
+			err := fmt.Errorf("Error getting an IPv4 address from the bridge %s: cannot find any IPv4 address", iface.Bridge)
+			if config.NetIPv6 {
+				err = fmt.Errorf("Error getting an IP address from the bridge %s: cannot find any IPv4 or global IPv6 address", iface.Bridge)
 			}
-			ip = ip.To4()
-			if ip == nil {
-				continue
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	default:
+		// The host address seen by the guest is only known when the tap
+		// interface is routed, or when the HTTP server is bound to it.
+		if iface.Backend == "tap" {
+			if tapInterface, err := net.InterfaceByName(iface.Ifname); err == nil {
+				if addrs, err := tapInterface.Addrs(); err == nil {
+					hostIP = bridgeHTTPIP(addrs, config.NetIPv6)
+				}
 			}
-			hostIP = ip.String()
-			break
+		}
+		if hostIP == "" && config.HTTPAddress != "0.0.0.0" {
+			hostIP = config.HTTPAddress
 		}
 		if hostIP == "" {
-			err := fmt.Errorf("Error getting an IPv4 address from the bridge %s: cannot find any IPv4 address", config.NetBridge)
-			ui.Error(err.Error())
-			return multistep.ActionHalt
+			ui.Message(fmt.Sprintf("Cannot discover the HTTP IP with the %s network backend, set http_bind_address to use {{ .HTTPIP }}.", iface.Backend))
 		}
 	}
 
 	state.Put("http_ip", hostIP)
+	(&packerbuilderdata.GeneratedData{State: state}).Put("HTTPIP", hostIP)
 
 	return multistep.ActionContinue
 }
 
 func (s *stepHTTPIPDiscover) Cleanup(state multistep.StateBag) {}
+
+// bridgeHTTPIP returns the first IPv4 address of the bridge. When allowIPv6
+// is set and the bridge has no IPv4 address, the first global IPv6 address is
+// returned instead, link-local addresses being ambiguous for the guest.
+func bridgeHTTPIP(addrs []net.Addr, allowIPv6 bool) string {
+	var ipv6 string
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPNet:
+			ip = v.IP
+		case *net.IPAddr:
+			ip = v.IP
+		}
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String()
+		}
+		if allowIPv6 && ipv6 == "" && ip.IsGlobalUnicast() {
+			ipv6 = ip.String()
+		}
+	}
+	return ipv6
+}
@@ -3,6 +3,8 @@ package qemu
 import (
 	"bytes"
 	"context"
+	"net"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -31,4 +33,51 @@ func TestStepHTTPIPDiscover_Run(t *testing.T) {
 	if httpIp != hostIp {
 		t.Fatalf("bad: Http ip is %s but was supposed to be %s", httpIp, hostIp)
 	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if !reflect.DeepEqual(generatedData, map[string]interface{}{"HTTPIP": hostIp}) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepHTTPIPDiscover_Restricted(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", &packersdk.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	state.Put("config", &Config{NetworkMode: "restricted"})
+	step := new(stepHTTPIPDiscover)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if httpIp := state.Get("http_ip").(string); httpIp != "10.0.2.254" {
+		t.Fatalf("bad: Http ip is %s but was supposed to be 10.0.2.254", httpIp)
+	}
+}
+
+func TestBridgeHTTPIP(t *testing.T) {
+	ipv4 := &net.IPNet{IP: net.ParseIP("192.168.122.1"), Mask: net.CIDRMask(24, 32)}
+	global := &net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)}
+	linkLocal := &net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}
+
+	type testCase struct {
+		Addrs     []net.Addr
+		AllowIPv6 bool
+		Expected  string
+		Reason    string
+	}
+
+	testCases := []testCase{
+		{[]net.Addr{linkLocal, global, ipv4}, true, "192.168.122.1", "IPv4 should be preferred"},
+		{[]net.Addr{linkLocal, global}, true, "2001:db8::1", "global IPv6 should be used without IPv4"},
+		{[]net.Addr{linkLocal, global}, false, "", "IPv6 should not be used unless enabled"},
+		{[]net.Addr{linkLocal}, true, "", "link-local IPv6 should not be used"},
+	}
+
+	for _, tc := range testCases {
+		if ip := bridgeHTTPIP(tc.Addrs, tc.AllowIPv6); ip != tc.Expected {
+			t.Errorf("%s: got %q, expected %q", tc.Reason, ip, tc.Expected)
+		}
+	}
 }
@@ -2,24 +2,80 @@ package qemu
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	gonet "net"
+	"syscall"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/net"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
+// maxIPv6PortAttempts is how many communicator ports are tried before giving
+// up finding one free on both the bind address and [::1].
+const maxIPv6PortAttempts = 10
+
 // This step adds a NAT port forwarding definition so that SSH or WinRM is available
-// on the guest machine.
+// on the guest machine, as well as the additional port forwards.
 type stepPortForward struct {
 	CommunicatorType string
-	NetBridge        string
+	NetworkInterface NetworkInterfaceConfig
+	PortForwards     []PortForwardConfig
+	// Whether the communicator port is also forwarded from [::1].
+	NetIPv6 bool
 
-	l *net.Listener
+	l         *net.Listener
+	listeners []*net.Listener
 }
 
 func (s *stepPortForward) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if action := s.forwardCommunicatorPort(ctx, state); action != multistep.ActionContinue {
+		return action
+	}
+	if commHostPort, ok := state.Get("commHostPort").(int); ok {
+		(&packerbuilderdata.GeneratedData{State: state}).Put("SSHHostPort", commHostPort)
+	}
+
+	if len(s.PortForwards) == 0 {
+		return multistep.ActionContinue
+	}
+
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packersdk.Ui)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+
+	hostPorts := make(map[string]int)
+	for _, pf := range s.PortForwards {
+		log.Printf("Looking for available port for %s between %d and %d", pf.Name, pf.HostPortMin, pf.HostPortMax)
+		l, err := net.ListenRangeConfig{
+			Addr:    config.VNCBindAddress,
+			Min:     pf.HostPortMin,
+			Max:     pf.HostPortMax,
+			Network: "tcp",
+		}.Listen(ctx)
+		if err != nil {
+			err := fmt.Errorf("Error finding port for %s: %s", pf.Name, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		l.Listener.Close() // free port, but don't unlock lock file
+		s.listeners = append(s.listeners, l)
+
+		ui.Say(fmt.Sprintf("Forwarding %s port %d of the guest from port %d for %s.", pf.Protocol, pf.GuestPort, l.Port, pf.Name))
+		hostPorts[pf.Name] = l.Port
+		generatedData.Put(pf.generatedDataName(), l.Port)
+	}
+
+	state.Put("port_forwards", hostPorts)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepPortForward) forwardCommunicatorPort(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packersdk.Ui)
 
@@ -27,8 +83,8 @@ func (s *stepPortForward) Run(ctx context.Context, state multistep.StateBag) mul
 		ui.Message("No communicator is set; skipping port forwarding setup.")
 		return multistep.ActionContinue
 	}
-	if s.NetBridge != "" {
-		ui.Message("net_bridge is set; skipping port forwarding setup.")
+	if s.NetworkInterface.Backend != "user" && s.NetworkInterface.Backend != "passt" {
+		ui.Message(fmt.Sprintf("The communicator network interface uses the %s backend; skipping port forwarding setup.", s.NetworkInterface.Backend))
 		return multistep.ActionContinue
 	}
 
@@ -41,20 +97,40 @@ func (s *stepPortForward) Run(ctx context.Context, state multistep.StateBag) mul
 	}
 
 	log.Printf("Looking for available communicator (SSH, WinRM, etc) port between %d and %d", config.CommConfig.HostPortMin, config.CommConfig.HostPortMax)
-	var err error
-	s.l, err = net.ListenRangeConfig{
-		Addr:    config.VNCBindAddress,
-		Min:     config.CommConfig.HostPortMin,
-		Max:     config.CommConfig.HostPortMax,
-		Network: "tcp",
-	}.Listen(ctx)
-	if err != nil {
-		err := fmt.Errorf("Error finding port: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	for attempt := 1; ; attempt++ {
+		var err error
+		s.l, err = net.ListenRangeConfig{
+			Addr:    config.VNCBindAddress,
+			Min:     config.CommConfig.HostPortMin,
+			Max:     config.CommConfig.HostPortMax,
+			Network: "tcp",
+		}.Listen(ctx)
+		if err != nil {
+			err := fmt.Errorf("Error finding port: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.l.Listener.Close() // free port, but don't unlock lock file
+
+		// The port is also forwarded from [::1] with the user backend, where
+		// it must be free as well.
+		if !s.NetIPv6 || s.NetworkInterface.Backend != "user" || ipv6LoopbackPortFree(s.l.Port) {
+			break
+		}
+		if attempt == maxIPv6PortAttempts {
+			err := fmt.Errorf("Error finding port: no port between %d and %d is free on both %s and [::1]",
+				config.CommConfig.HostPortMin, config.CommConfig.HostPortMax, config.VNCBindAddress)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		log.Printf("Port %d is in use on [::1], looking for another one", s.l.Port)
+		if err := s.l.Close(); err != nil {
+			log.Printf("failed to unlock port lockfile: %v", err)
+		}
+		s.l = nil
 	}
-	s.l.Listener.Close() // free port, but don't unlock lock file
 	commHostPort = s.l.Port
 	ui.Say(fmt.Sprintf("Found port for communicator (SSH, WinRM, etc): %d.", commHostPort))
 
@@ -71,4 +147,20 @@ func (s *stepPortForward) Cleanup(state multistep.StateBag) {
 			log.Printf("failed to unlock port lockfile: %v", err)
 		}
 	}
+	for _, l := range s.listeners {
+		if err := l.Close(); err != nil {
+			log.Printf("failed to unlock port lockfile: %v", err)
+		}
+	}
+}
+
+// ipv6LoopbackPortFree returns whether port is not in use on [::1]. Other
+// listen errors, like a host without IPv6, are not specific to the port.
+func ipv6LoopbackPortFree(port int) bool {
+	l, err := gonet.Listen("tcp6", fmt.Sprintf("[::1]:%d", port))
+	if err != nil {
+		return !errors.Is(err, syscall.EADDRINUSE)
+	}
+	l.Close()
+	return true
 }
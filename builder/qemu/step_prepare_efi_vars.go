@@ -0,0 +1,81 @@
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// efiVarsFileName is the name of the UEFI variable store in the output
+// directory.
+const efiVarsFileName = "efivars.fd"
+
+// This step copies the UEFI variable store template to the output directory,
+// enrolling the custom Secure Boot keys if needed.
+//
+// Uses:
+//   driver Driver
+//   ui     packersdk.Ui
+//
+// Produces:
+//   efi_vars_path string - The path to the UEFI variable store.
+type stepPrepareEFIVars struct {
+	EFIFirmwareVars string
+	SecureBootKeys  *SecureBootKeysConfig
+	OutputDir       string
+}
+
+func (s *stepPrepareEFIVars) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.EFIFirmwareVars == "" {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	varsPath := filepath.Join(s.OutputDir, efiVarsFileName)
+
+	var err error
+	if s.SecureBootKeys != nil {
+		ui.Say("Enrolling Secure Boot keys in the UEFI variable store...")
+		err = enrollSecureBootKeys(s.SecureBootKeys, s.EFIFirmwareVars, varsPath)
+	} else {
+		ui.Say("Copying the UEFI variable store...")
+		driver := state.Get("driver").(Driver)
+		err = driver.Copy(s.EFIFirmwareVars, varsPath)
+	}
+	if err != nil {
+		err := fmt.Errorf("Error preparing the UEFI variable store: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("efi_vars_path", varsPath)
+	return multistep.ActionContinue
+}
+
+func (s *stepPrepareEFIVars) Cleanup(state multistep.StateBag) {}
+
+func enrollSecureBootKeys(keys *SecureBootKeysConfig, input string, output string) error {
+	virtFwVarsPath, err := exec.LookPath("virt-fw-vars")
+	if err != nil {
+		return fmt.Errorf("virt-fw-vars is required to enroll the Secure Boot keys: %s", err)
+	}
+
+	var stderr bytes.Buffer
+	args := keys.virtFwVarsArgs(input, output)
+	cmd := exec.Command(virtFwVarsPath, args...)
+	cmd.Stderr = &stderr
+
+	log.Printf("Executing virt-fw-vars: %s %#v", virtFwVarsPath, args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("virt-fw-vars failed: %s\nStderr: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package qemu
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPrepareEFIVars_impl(t *testing.T) {
+	var _ multistep.Step = new(stepPrepareEFIVars)
+}
+
+func TestStepPrepareEFIVars(t *testing.T) {
+	state := testState(t)
+	step := &stepPrepareEFIVars{
+		EFIFirmwareVars: "/usr/share/OVMF/OVMF_VARS_4M.ms.fd",
+		OutputDir:       "output-foo",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if !state.Get("driver").(*DriverMock).CopyCalled {
+		t.Fatal("the variable store should be copied")
+	}
+	if path := state.Get("efi_vars_path"); path != filepath.Join("output-foo", "efivars.fd") {
+		t.Fatalf("bad efi_vars_path: %#v", path)
+	}
+
+	// Without UEFI firmware, nothing is done
+	state = testState(t)
+	step = &stepPrepareEFIVars{OutputDir: "output-foo"}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("efi_vars_path"); ok {
+		t.Fatal("efi_vars_path should not be set")
+	}
+}
+
+func TestSecureBootKeysConfig_virtFwVarsArgs(t *testing.T) {
+	keys := &SecureBootKeysConfig{
+		PK:        "pk.pem",
+		KEK:       []string{"kek.pem"},
+		DB:        []string{"db1.pem", "db2.pem"},
+		OwnerGUID: "9a4e1e3f-4b6c-4d55-8d3c-7c1b2c3d4e5f",
+	}
+
+	expected := []string{
+		"--input", "vars.fd", "--output", "efivars.fd",
+		"--set-pk", "9a4e1e3f-4b6c-4d55-8d3c-7c1b2c3d4e5f", "pk.pem",
+		"--add-kek", "9a4e1e3f-4b6c-4d55-8d3c-7c1b2c3d4e5f", "kek.pem",
+		"--add-db", "9a4e1e3f-4b6c-4d55-8d3c-7c1b2c3d4e5f", "db1.pem",
+		"--add-db", "9a4e1e3f-4b6c-4d55-8d3c-7c1b2c3d4e5f", "db2.pem",
+		"--secure-boot",
+	}
+	if args := keys.virtFwVarsArgs("vars.fd", "efivars.fd"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad args: %#v", args)
+	}
+}
+
+func TestGenerateGUID(t *testing.T) {
+	guid := generateGUID("myvm")
+	if !guidRe.MatchString(guid) {
+		t.Fatalf("bad GUID: %s", guid)
+	}
+	if guid != generateGUID("myvm") || guid == generateGUID("othervm") {
+		t.Fatal("GUIDs should be generated from the name")
+	}
+}
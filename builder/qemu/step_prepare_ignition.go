@@ -0,0 +1,168 @@
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// This step writes the Ignition config passed to the VM, translating it from
+// Butane and authorizing the temporary SSH key if needed.
+//
+// Uses:
+//   ui packersdk.Ui
+//
+// Produces:
+//   ignition_config_path string - The path to the Ignition config.
+type stepPrepareIgnition struct {
+	Ignition *IgnitionConfig
+	Comm     *communicator.Config
+
+	configPath string
+}
+
+func (s *stepPrepareIgnition) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Ignition == nil {
+		return multistep.ActionContinue
+	}
+
+	ui := state.Get("ui").(packersdk.Ui)
+	ui.Say("Preparing Ignition config...")
+
+	config, err := s.ignitionConfig()
+	if err != nil {
+		err := fmt.Errorf("Error preparing Ignition config: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	f, err := os.CreateTemp("", "packer-ignition-*.ign")
+	if err != nil {
+		err := fmt.Errorf("Error creating Ignition config file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.configPath = f.Name()
+
+	_, err = f.Write(config)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		err := fmt.Errorf("Error writing Ignition config file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("ignition_config_path", s.configPath)
+	return multistep.ActionContinue
+}
+
+func (s *stepPrepareIgnition) Cleanup(state multistep.StateBag) {
+	if s.configPath == "" {
+		return
+	}
+
+	if err := os.Remove(s.configPath); err != nil {
+		log.Printf("Error removing Ignition config file %s: %s", s.configPath, err)
+	}
+}
+
+func (s *stepPrepareIgnition) ignitionConfig() ([]byte, error) {
+	config := []byte(s.Ignition.Content)
+	if s.Ignition.File != "" {
+		var err error
+		config, err = os.ReadFile(s.Ignition.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Ignition.Butane {
+		var err error
+		config, err = butaneTranslate(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(s.Comm.SSHPublicKey) > 0 {
+		publicKey := strings.TrimSpace(string(s.Comm.SSHPublicKey))
+		return ignitionAuthorizeKey(config, s.Comm.SSHUsername, publicKey)
+	}
+
+	return config, nil
+}
+
+// butaneTranslate translates a Butane config to an Ignition config with the
+// butane binary found in the PATH.
+func butaneTranslate(config []byte) ([]byte, error) {
+	butanePath, err := exec.LookPath("butane")
+	if err != nil {
+		return nil, fmt.Errorf("butane is required to translate the config: %s", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(butanePath, "--strict")
+	cmd.Stdin = bytes.NewReader(config)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Printf("Executing butane: %s", butanePath)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("butane failed: %s\nStderr: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// ignitionAuthorizeKey adds the public key to the SSH authorized keys of the
+// user in the passwd section of an Ignition config. The user is created in
+// the wheel group if the config doesn't declare it.
+func ignitionAuthorizeKey(config []byte, username string, publicKey string) ([]byte, error) {
+	var ignition map[string]interface{}
+	if err := json.Unmarshal(config, &ignition); err != nil {
+		return nil, fmt.Errorf("invalid Ignition config: %s", err)
+	}
+
+	passwd, ok := ignition["passwd"].(map[string]interface{})
+	if !ok {
+		passwd = make(map[string]interface{})
+	}
+	users, _ := passwd["users"].([]interface{})
+
+	found := false
+	for _, u := range users {
+		user, ok := u.(map[string]interface{})
+		if !ok || user["name"] != username {
+			continue
+		}
+		keys, _ := user["sshAuthorizedKeys"].([]interface{})
+		user["sshAuthorizedKeys"] = append(keys, publicKey)
+		found = true
+	}
+	if !found {
+		users = append(users, map[string]interface{}{
+			"name":              username,
+			"groups":            []string{"wheel"},
+			"sshAuthorizedKeys": []string{publicKey},
+		})
+	}
+
+	passwd["users"] = users
+	ignition["passwd"] = passwd
+
+	return json.Marshal(ignition)
+}
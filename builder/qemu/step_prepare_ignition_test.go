@@ -0,0 +1,88 @@
+package qemu
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+func TestStepPrepareIgnition_impl(t *testing.T) {
+	var _ multistep.Step = new(stepPrepareIgnition)
+}
+
+func TestStepPrepareIgnition(t *testing.T) {
+	state := testState(t)
+	step := &stepPrepareIgnition{
+		Ignition: &IgnitionConfig{Content: `{"ignition": {"version": "3.3.0"}}`},
+		Comm: &communicator.Config{
+			SSH: communicator.SSH{
+				SSHUsername:  "core",
+				SSHPublicKey: []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI packer\n"),
+			},
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	path, ok := state.Get("ignition_config_path").(string)
+	if !ok {
+		t.Fatal("should have an ignition config")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("ignition config should exist: %s", err)
+	}
+
+	step.Cleanup(state)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("ignition config should be removed: %s", err)
+	}
+}
+
+func TestIgnitionAuthorizeKey(t *testing.T) {
+	type testCase struct {
+		Config   string
+		Expected string
+		Reason   string
+	}
+
+	testCases := []testCase{
+		{
+			`{"ignition": {"version": "3.3.0"}}`,
+			`{"ignition": {"version": "3.3.0"}, "passwd": {"users": [{"name": "core", "groups": ["wheel"], "sshAuthorizedKeys": ["ssh-ed25519 key"]}]}}`,
+			"user should be created",
+		},
+		{
+			`{"ignition": {"version": "3.3.0"}, "passwd": {"users": [{"name": "core", "sshAuthorizedKeys": ["ssh-rsa other"]}, {"name": "admin"}]}}`,
+			`{"ignition": {"version": "3.3.0"}, "passwd": {"users": [{"name": "core", "sshAuthorizedKeys": ["ssh-rsa other", "ssh-ed25519 key"]}, {"name": "admin"}]}}`,
+			"key should be added to the existing user",
+		},
+	}
+
+	for _, tc := range testCases {
+		config, err := ignitionAuthorizeKey([]byte(tc.Config), "core", "ssh-ed25519 key")
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+
+		var got, expected interface{}
+		if err := json.Unmarshal(config, &got); err != nil {
+			t.Fatalf("should be valid JSON: %s", err)
+		}
+		if err := json.Unmarshal([]byte(tc.Expected), &expected); err != nil {
+			t.Fatalf("bad expected JSON: %s", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got %s", tc.Reason, config)
+		}
+	}
+
+	if _, err := ignitionAuthorizeKey([]byte("variant: fcos"), "core", "ssh-ed25519 key"); err == nil {
+		t.Fatal("should have error with a non JSON config")
+	}
+}
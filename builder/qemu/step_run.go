@@ -2,9 +2,14 @@ package qemu
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-version"
@@ -18,7 +23,12 @@ type stepRun struct {
 	DiskImage bool
 
 	atLeastVersion2 bool
-	ui              packersdk.Ui
+	// Whether QEMU forwards host ports from IPv6 addresses, since 6.1.
+	ipv6HostForward bool
+	// Whether QEMU takes the disable-ticketing=on SPICE option rather than
+	// disable-ticketing, since 5.0.
+	spiceTicketingValue bool
+	ui                  packersdk.Ui
 }
 
 func (s *stepRun) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
@@ -40,13 +50,17 @@ func (s *stepRun) Run(ctx context.Context, state multistep.StateBag) multistep.S
 		return multistep.ActionHalt
 	}
 	v2 := version.Must(version.NewVersion("2.0"))
+	v5 := version.Must(version.NewVersion("5.0"))
+	v6_1 := version.Must(version.NewVersion("6.1"))
 
 	s.atLeastVersion2 = qemuVersion.GreaterThanOrEqual(v2)
+	s.spiceTicketingValue = qemuVersion.GreaterThanOrEqual(v5)
+	s.ipv6HostForward = qemuVersion.GreaterThanOrEqual(v6_1)
 
 	// Generate the qemu command
 	command, err := s.getCommandArgs(config, state)
 	if err != nil {
-		err := fmt.Errorf("Error processing QemuArgs: %s", err)
+		err := fmt.Errorf("Error generating the qemu command: %s", err)
 		s.ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
@@ -70,7 +84,7 @@ func (s *stepRun) Cleanup(state multistep.StateBag) {
 	}
 }
 
-func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[string]interface{} {
+func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) (map[string]interface{}, error) {
 
 	defaultArgs := make(map[string]interface{})
 
@@ -83,6 +97,9 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 		bootDrive = "c"
 		message = "Starting VM, booting disk image"
 	}
+	if _, ok := state.GetOk("kernel_path"); ok {
+		message = "Starting VM, booting kernel directly"
+	}
 	s.ui.Say(message)
 	defaultArgs["-boot"] = bootDrive
 
@@ -103,20 +120,137 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 		defaultArgs["-machine"] = fmt.Sprintf("type=%s,accel=%s",
 			config.MachineType, config.Accelerator)
 	}
+	// Secure Boot relies on SMM to protect the UEFI variables from the guest
+	if config.SecureBoot {
+		defaultArgs["-machine"] = defaultArgs["-machine"].(string) + ",smm=on"
+		defaultArgs["-global"] = "driver=cfi.pflash01,property=secure,value=on"
+	}
+	// Without NUMA nodes, the memory backend holds all the memory of the VM
+	if config.MemoryBackend != "" && len(config.NUMANodes) == 0 {
+		defaultArgs["-machine"] = defaultArgs["-machine"].(string) + ",memory-backend=mem"
+	}
 
 	// Firmware
 	if config.Firmware != "" {
 		defaultArgs["-bios"] = config.Firmware
 	}
 
+	// Direct kernel boot
+	if kernelPath, ok := state.GetOk("kernel_path"); ok {
+		defaultArgs["-kernel"] = kernelPath.(string)
+		if initrdPath, ok := state.GetOk("initrd_path"); ok {
+			defaultArgs["-initrd"] = initrdPath.(string)
+		}
+		if config.KernelCmdline != "" {
+			ictx := bootTemplateContext(config, state)
+			cmdline, err := interpolate.Render(config.KernelCmdline, &ictx)
+			if err != nil {
+				return nil, fmt.Errorf("Error rendering kernel_cmdline: %s", err)
+			}
+			defaultArgs["-append"] = cmdline
+		}
+	}
+
+	// Configure "-fw_cfg" arguments
+	var fwCfgArgs []string
+	if len(config.FwCfg) > 0 {
+		ictx := bootTemplateContext(config, state)
+		for _, item := range config.FwCfg {
+			if item.File != "" {
+				fwCfgArgs = append(fwCfgArgs, fmt.Sprintf("name=%s,file=%s", item.Name, qemuEscape(item.File)))
+				continue
+			}
+			content, err := interpolate.Render(item.String, &ictx)
+			if err != nil {
+				return nil, fmt.Errorf("Error rendering fw_cfg %s: %s", item.Name, err)
+			}
+			fwCfgArgs = append(fwCfgArgs, fmt.Sprintf("name=%s,string=%s", item.Name, qemuEscape(content)))
+		}
+	}
+	if ignitionPath, ok := state.Get("ignition_config_path").(string); ok && ignitionFwCfgArchs[qemuArch(config.QemuBinary)] {
+		fwCfgArgs = append(fwCfgArgs, fmt.Sprintf("name=%s,file=%s", ignitionFwCfgName, qemuEscape(ignitionPath)))
+	}
+	if len(fwCfgArgs) > 0 {
+		defaultArgs["-fw_cfg"] = fwCfgArgs
+	}
+
+	// Configure "-smbios" arguments
+	if len(config.SMBIOS) > 0 {
+		smbiosArgs := make([]string, 0, len(config.SMBIOS))
+		for _, structure := range config.SMBIOS {
+			smbiosArgs = append(smbiosArgs, smbiosArgument(structure))
+		}
+		defaultArgs["-smbios"] = smbiosArgs
+	}
+
 	// Configure "-netdev" arguments
-	defaultArgs["-netdev"] = fmt.Sprintf("bridge,id=user.0,br=%s", config.NetBridge)
-	if config.NetBridge == "" {
-		defaultArgs["-netdev"] = fmt.Sprintf("user,id=user.0")
-		if config.CommConfig.Comm.Type != "none" {
-			commHostPort := state.Get("commHostPort").(int)
-			defaultArgs["-netdev"] = fmt.Sprintf("user,id=user.0,hostfwd=tcp::%v-:%d", commHostPort, config.CommConfig.Comm.Port())
+	_, commNetdevID := config.commNetworkInterface()
+	portForwardNetdevID := config.portForwardNetdevID()
+	var netdevArgs []string
+	for i, iface := range config.networkInterfaces() {
+		id := netdevID(i)
+		netdev := iface.netdevArgument(id)
+		if iface.Backend == "passt" {
+			netdev = passtNetdevArgument(id, state.Get("passt_sockets").(map[string]string)[id])
+		}
+		if iface.Backend == "user" {
+			if config.NetworkMode == "restricted" {
+				netdev += ",restrict=on"
+			}
+			if config.NetIPv6 {
+				netdev += ",ipv6=on"
+			}
+			if id == commNetdevID && config.CommConfig.Comm.Type != "none" {
+				commHostPort := state.Get("commHostPort").(int)
+				netdev += fmt.Sprintf(",hostfwd=tcp::%v-:%d", commHostPort, config.CommConfig.Comm.Port())
+				if config.NetIPv6 && s.ipv6HostForward {
+					netdev += fmt.Sprintf(",hostfwd=tcp:[::1]:%v-:%d", commHostPort, config.CommConfig.Comm.Port())
+				} else if config.NetIPv6 {
+					s.ui.Message("QEMU 6.1 or later is required to forward the communicator port from [::1], skipping.")
+				}
+			}
+			if id == portForwardNetdevID && len(config.PortForwards) > 0 {
+				hostPorts := state.Get("port_forwards").(map[string]int)
+				for _, pf := range config.PortForwards {
+					netdev += fmt.Sprintf(",hostfwd=%s::%d-:%d", pf.Protocol, hostPorts[pf.Name], pf.GuestPort)
+				}
+			}
+			if id == portForwardNetdevID && config.NetworkMode == "restricted" {
+				guestForwards, err := restrictedGuestForwards(config, state)
+				if err != nil {
+					return nil, err
+				}
+				netdev += guestForwards
+			}
+			if id == portForwardNetdevID {
+				for _, gf := range config.GuestForwards {
+					guestForward, err := gf.guestfwdOption()
+					if err != nil {
+						return nil, err
+					}
+					netdev += "," + guestForward
+				}
+			}
+		}
+		netdevArgs = append(netdevArgs, netdev)
+	}
+	if config.NetworkMode == "restricted" {
+		var reachable []string
+		if httpPort, _ := state.Get("http_port").(int); httpPort != 0 {
+			reachable = append(reachable, "the HTTP server")
 		}
+		reachable = append(reachable, config.NetworkAllowlist...)
+		if len(reachable) == 0 {
+			s.ui.Say("The network of the VM is restricted, the guest can't reach the host or the internet")
+		} else {
+			s.ui.Say(fmt.Sprintf("The network of the VM is restricted, the guest can only reach %s at %s",
+				strings.Join(reachable, ", "), restrictedNetworkGuestIP))
+		}
+	}
+	if len(netdevArgs) == 1 {
+		defaultArgs["-netdev"] = netdevArgs[0]
+	} else {
+		defaultArgs["-netdev"] = netdevArgs
 	}
 
 	// Configure "-vnc" arguments
@@ -125,11 +259,14 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 	vncPort := state.Get("vnc_port").(int)
 	vncIP := config.VNCBindAddress
 
-	vncRealAddress := fmt.Sprintf("%s:%d", vncIP, vncPort)
-	vncPort = vncPort - 5900
-	vncArgs := fmt.Sprintf("%s:%d", vncIP, vncPort)
+	vncRealAddress := fmt.Sprintf("vnc://%s:%d", vncIP, vncPort)
+	vncArgs := fmt.Sprintf("%s:%d", vncIP, vncPort-5900)
+	if socketPath, ok := state.Get("vnc_socket_path").(string); ok {
+		vncRealAddress = "the socket " + socketPath
+		vncArgs = "unix:" + qemuEscape(socketPath)
+	}
 	if config.VNCUsePassword {
-		vncArgs = fmt.Sprintf("%s:%d,password", vncIP, vncPort)
+		vncArgs += ",password"
 	}
 	defaultArgs["-vnc"] = vncArgs
 
@@ -141,14 +278,93 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 		s.ui.Message(message)
 	}
 
+	// Configure "-spice" arguments
+	if config.DisplayProtocol == "spice" {
+		spicePort := state.Get("spice_port").(int)
+		spicePass, _ := state.Get("spice_password").(string)
+		spiceArgs := fmt.Sprintf("port=%d,addr=%s", spicePort, config.SPICEBindAddress)
+		if spicePass == "" && s.spiceTicketingValue {
+			spiceArgs += ",disable-ticketing=on"
+		} else if spicePass == "" {
+			spiceArgs += ",disable-ticketing"
+		}
+		defaultArgs["-spice"] = spiceArgs
+
+		message = getSpiceConnectionMessage(config.Headless, spiceAddress(config.SPICEBindAddress, spicePort), spicePass)
+		if message != "" {
+			s.ui.Message(message)
+		}
+	}
+
 	// Configure "-m" memory argument
-	defaultArgs["-m"] = fmt.Sprintf("%dM", config.MemorySize)
+	if config.MaxMemory != "" {
+		defaultArgs["-m"] = fmt.Sprintf("size=%dM,slots=%d,maxmem=%dM", config.memoryMiB, config.MemorySlots, config.maxMemoryMiB)
+	} else {
+		defaultArgs["-m"] = fmt.Sprintf("%dM", config.memoryMiB)
+	}
+
+	// Configure the memory backend and RNG "-object" and the "-numa" arguments
+	var objectArgs []string
+	if len(config.NUMANodes) > 0 {
+		var numaArgs []string
+		for i, node := range config.NUMANodes {
+			memdev := fmt.Sprintf("mem.%d", i)
+			objectArgs = append(objectArgs, config.memoryBackendArgument(memdev, node.memoryMiB, node.HostNodes))
+			numaArgs = append(numaArgs, node.numaArgument(i, memdev))
+		}
+		defaultArgs["-numa"] = numaArgs
+	} else if config.MemoryBackend != "" {
+		objectArgs = append(objectArgs, config.memoryBackendArgument("mem", config.memoryMiB, ""))
+	}
+	if config.RNG.True() {
+		objectArgs = append(objectArgs, "rng-random,id=rng,filename=/dev/urandom")
+	}
+	if len(objectArgs) > 0 {
+		defaultArgs["-object"] = objectArgs
+	}
+
+	// Configure the shared folders "-chardev" and "-fsdev" arguments
+	var chardevArgs, fsdevArgs []string
+	for _, sf := range config.SharedFolders {
+		switch sf.Type {
+		case "virtiofs":
+			socketPath := state.Get("virtiofs_sockets").(map[string]string)[sf.Name]
+			chardevArgs = append(chardevArgs, fmt.Sprintf("socket,id=fs.%s,path=%s", sf.Name, qemuEscape(socketPath)))
+		case "9p":
+			fsdevArgs = append(fsdevArgs, sf.fsdevArgument("fs."+sf.Name))
+		}
+	}
+	if len(chardevArgs) > 0 {
+		defaultArgs["-chardev"] = chardevArgs
+	}
+	if len(fsdevArgs) > 0 {
+		defaultArgs["-fsdev"] = fsdevArgs
+	}
 
 	// Configure "-smp" processor hardware arguments
-	if config.CpuCount > 1 {
+	if config.Sockets > 0 {
+		defaultArgs["-smp"] = fmt.Sprintf("cpus=%d,sockets=%d,cores=%d,threads=%d",
+			config.CpuCount, config.Sockets, config.Cores, config.Threads)
+	} else if config.CpuCount > 1 {
 		defaultArgs["-smp"] = fmt.Sprintf("cpus=%d,sockets=%d", config.CpuCount, config.CpuCount)
 	}
 
+	// Configure "-cpu" model argument
+	if config.CPUModel != "" {
+		defaultArgs["-cpu"] = strings.Join(append([]string{config.CPUModel}, config.CPUFeatures...), ",")
+	}
+
+	// Configure the "-vga" adapter
+	if config.VGA != "" {
+		defaultArgs["-vga"] = config.VGA
+	}
+
+	// Configure the "-audiodev" backend of the sound card, which discards the
+	// sound
+	if config.AudioDevice != "" {
+		defaultArgs["-audiodev"] = "none,id=audio"
+	}
+
 	// Configure "-fda" floppy disk attachment
 	if floppyPathRaw, ok := state.GetOk("floppy_path"); ok {
 		defaultArgs["-fda"] = floppyPathRaw.(string)
@@ -179,7 +395,50 @@ func (s *stepRun) getDefaultArgs(config *Config, state multistep.StateBag) map[s
 	defaultArgs["-device"] = deviceArgs
 	defaultArgs["-drive"] = driveArgs
 
-	return defaultArgs
+	return defaultArgs, nil
+}
+
+// restrictedGuestForwards returns the guestfwd options exposing the HTTP
+// server and the allowlist to the guest in the restricted network mode.
+func restrictedGuestForwards(config *Config, state multistep.StateBag) (string, error) {
+	var targets []string
+
+	if httpPort, _ := state.Get("http_port").(int); httpPort != 0 {
+		httpAddress := config.HTTPAddress
+		if httpAddress == "" || httpAddress == "0.0.0.0" {
+			httpAddress = "127.0.0.1"
+		}
+		targets = append(targets, net.JoinHostPort(httpAddress, strconv.Itoa(httpPort)))
+	}
+	targets = append(targets, config.NetworkAllowlist...)
+
+	guestForwards := ""
+	for _, target := range targets {
+		_, port, _ := net.SplitHostPort(target)
+		relay, err := relayCommand(target)
+		if err != nil {
+			return "", fmt.Errorf("Error forwarding %s to the guest: %s", target, err)
+		}
+		guestForwards += fmt.Sprintf(",guestfwd=tcp:%s:%s-cmd:%s", restrictedNetworkGuestIP, port, qemuEscape(relay))
+	}
+	return guestForwards, nil
+}
+
+// relayCommand returns the command relaying a guest connection on its
+// standard input and output to the host:port target. QEMU runs it for each
+// connection, a tcp guestfwd target only accepting a single one.
+func relayCommand(target string) (string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath("socat"); err == nil {
+		return fmt.Sprintf("socat - TCP:%s", net.JoinHostPort(host, port)), nil
+	}
+	if _, err := exec.LookPath("nc"); err == nil {
+		return fmt.Sprintf("nc %s %s", host, port), nil
+	}
+	return "", errors.New("socat or nc is required on the host to relay the connections of the guest")
 }
 
 func getVncConnectionMessage(headless bool, vnc string, vncPass string) string {
@@ -194,18 +453,32 @@ func getVncConnectionMessage(headless bool, vnc string, vncPass string) string {
 		if vncPass != "" {
 			return fmt.Sprintf(
 				"The VM will be run headless, without a GUI. If you want to\n"+
-					"view the screen of the VM, connect via VNC to vnc://%s\n"+
+					"view the screen of the VM, connect via VNC to %s\n"+
 					"with the password: %s", vnc, vncPass)
 		}
 
 		return fmt.Sprintf(
 			"The VM will be run headless, without a GUI. If you want to\n"+
 				"view the screen of the VM, connect via VNC without a password to\n"+
-				"vnc://%s", vnc)
+				"%s", vnc)
 	}
 	return ""
 }
 
+func getSpiceConnectionMessage(headless bool, spice string, spicePass string) string {
+	if !headless {
+		return ""
+	}
+	if spicePass != "" {
+		return fmt.Sprintf(
+			"You can also connect via SPICE, with remote-viewer for example, to\n"+
+				"%s with the password: %s", spice, spicePass)
+	}
+	return fmt.Sprintf(
+		"You can also connect via SPICE, with remote-viewer for example,\n"+
+			"without a password to %s", spice)
+}
+
 func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag) ([]string, []string) {
 	var deviceArgs []string
 	var driveArgs []string
@@ -214,6 +487,16 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 	vmName := config.VMName
 	imgPath := filepath.Join(config.OutputDir, vmName)
 
+	// Load the UEFI firmware in flash memory, with its variable store
+	if config.EFIFirmwareCode != "" {
+		driveArgs = append(driveArgs,
+			fmt.Sprintf("if=pflash,format=raw,unit=0,file=%s,readonly=on", qemuEscape(config.EFIFirmwareCode)))
+		if varsPath, ok := state.Get("efi_vars_path").(string); ok {
+			driveArgs = append(driveArgs,
+				fmt.Sprintf("if=pflash,format=raw,unit=1,file=%s", qemuEscape(varsPath)))
+		}
+	}
+
 	// Configure virtual hard drives
 	if s.atLeastVersion2 {
 		drivesToAttach := []string{}
@@ -249,7 +532,15 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 		driveArgs = append(driveArgs, fmt.Sprintf("file=%s,if=%s,cache=%s,format=%s", imgPath, config.DiskInterface, config.DiskCache, config.Format))
 	}
 
-	deviceArgs = append(deviceArgs, fmt.Sprintf("%s,netdev=user.0", config.NetDevice))
+	for i, iface := range config.networkInterfaces() {
+		deviceArgs = append(deviceArgs, iface.deviceArgument(netdevID(i)))
+	}
+
+	// Attach the Ignition config as a drive where fw_cfg isn't available
+	if ignitionPath, ok := state.Get("ignition_config_path").(string); ok && !ignitionFwCfgArchs[qemuArch(config.QemuBinary)] {
+		driveArgs = append(driveArgs, fmt.Sprintf("file=%s,if=none,format=raw,readonly=on,id=ignition", qemuEscape(ignitionPath)))
+		deviceArgs = append(deviceArgs, "virtio-blk,serial=ignition,drive=ignition")
+	}
 
 	// Configure virtual CDs
 	cdPaths := []string{}
@@ -265,6 +556,10 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 			cdPaths = append(cdPaths, cdFilesPath)
 		}
 	}
+	// Add the cloud-init seed, if it exists
+	if seedPath, ok := state.Get("cloud_init_seed_path").(string); ok {
+		cdPaths = append(cdPaths, seedPath)
+	}
 	for i, cdPath := range cdPaths {
 		if config.CDROMInterface == "" {
 			driveArgs = append(driveArgs, fmt.Sprintf("file=%s,media=cdrom", cdPath))
@@ -280,6 +575,43 @@ func (s *stepRun) getDeviceAndDriveArgs(config *Config, state multistep.StateBag
 		}
 	}
 
+	if config.MemoryBalloon {
+		deviceArgs = append(deviceArgs, "virtio-balloon")
+	}
+
+	if config.RNG.True() {
+		rngDevice := "virtio-rng-pci,rng=rng"
+		if config.RNGMaxBytes > 0 {
+			rngDevice += fmt.Sprintf(",max-bytes=%d,period=%d", config.RNGMaxBytes, config.RNGPeriod.Milliseconds())
+		}
+		deviceArgs = append(deviceArgs, rngDevice)
+	}
+
+	if config.VideoDevice != "" && config.VideoDevice != "none" {
+		deviceArgs = append(deviceArgs, config.VideoDevice)
+	}
+
+	if config.USBController != "" {
+		deviceArgs = append(deviceArgs, config.USBController+",id=usb")
+	}
+	deviceArgs = append(deviceArgs, config.InputDevices...)
+	switch config.AudioDevice {
+	case "":
+	case "intel-hda", "ich9-intel-hda":
+		deviceArgs = append(deviceArgs, config.AudioDevice, "hda-duplex,audiodev=audio")
+	default:
+		deviceArgs = append(deviceArgs, config.AudioDevice+",audiodev=audio")
+	}
+
+	for _, sf := range config.SharedFolders {
+		switch sf.Type {
+		case "virtiofs":
+			deviceArgs = append(deviceArgs, fmt.Sprintf("vhost-user-fs-pci,chardev=fs.%s,tag=%s", sf.Name, sf.Tag))
+		case "9p":
+			deviceArgs = append(deviceArgs, fmt.Sprintf("virtio-9p-pci,fsdev=fs.%s,mount_tag=%s", sf.Name, sf.Tag))
+		}
+	}
+
 	return deviceArgs, driveArgs
 }
 
@@ -301,24 +633,28 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 		httpPort := state.Get("http_port").(int)
 
 		type qemuArgsTemplateData struct {
-			HTTPIP      string
-			HTTPPort    int
-			HTTPDir     string
-			HTTPContent map[string]string
-			OutputDir   string
-			Name        string
-			SSHHostPort int
+			HTTPIP        string
+			HTTPHost      string
+			HTTPPort      int
+			HTTPDir       string
+			HTTPContent   map[string]string
+			OutputDir     string
+			Name          string
+			SSHHostPort   int
+			GuestForwards map[string]string
 		}
 
 		ictx := config.ctx
 		ictx.Data = qemuArgsTemplateData{
-			HTTPIP:      httpIp,
-			HTTPPort:    httpPort,
-			HTTPDir:     config.HTTPDir,
-			HTTPContent: config.HTTPContent,
-			OutputDir:   config.OutputDir,
-			Name:        config.VMName,
-			SSHHostPort: commHostPort,
+			HTTPIP:        httpIp,
+			HTTPHost:      bracketIPv6(httpIp),
+			HTTPPort:      httpPort,
+			HTTPDir:       config.HTTPDir,
+			HTTPContent:   config.HTTPContent,
+			OutputDir:     config.OutputDir,
+			Name:          config.VMName,
+			SSHHostPort:   commHostPort,
+			GuestForwards: config.guestForwardAddresses(),
 		}
 
 		// Interpolate each string in qemuargs
@@ -357,8 +693,18 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 
 	// Check if we are missing the netDevice #6804
 	if x, ok := inArgs["-device"]; ok {
-		if !strings.Contains(strings.Join(x, ""), config.NetDevice) {
-			inArgs["-device"] = append(inArgs["-device"], fmt.Sprintf("%s,netdev=user.0", config.NetDevice))
+		devices := strings.Join(x, "")
+		ifaces := config.networkInterfaces()
+		for i, iface := range ifaces {
+			id := netdevID(i)
+			if strings.Contains(devices, "netdev="+id) {
+				continue
+			}
+			// A single interface may be attached to a netdev of the user
+			if len(ifaces) == 1 && strings.Contains(devices, iface.Model) {
+				continue
+			}
+			inArgs["-device"] = append(inArgs["-device"], iface.deviceArgument(id))
 		}
 	}
 
@@ -378,11 +724,52 @@ func (s *stepRun) applyUserOverrides(defaultArgs map[string]interface{}, config
 }
 
 func (s *stepRun) getCommandArgs(config *Config, state multistep.StateBag) ([]string, error) {
-	defaultArgs := s.getDefaultArgs(config, state)
+	defaultArgs, err := s.getDefaultArgs(config, state)
+	if err != nil {
+		return nil, err
+	}
 
 	return s.applyUserOverrides(defaultArgs, config, state)
 }
 
+// bootTemplateContext returns the context used to render the templates
+// available before the VM boots, such as kernel_cmdline.
+func bootTemplateContext(config *Config, state multistep.StateBag) interpolate.Context {
+	ictx := config.ctx
+	ictx.Data = &bootCommandTemplateData{
+		HTTPIP:        state.Get("http_ip").(string),
+		HTTPHost:      bracketIPv6(state.Get("http_ip").(string)),
+		HTTPPort:      state.Get("http_port").(int),
+		Name:          config.VMName,
+		GuestForwards: config.guestForwardAddresses(),
+	}
+	return ictx
+}
+
+// qemuEscape escapes the commas of a value used in a qemu option list.
+func qemuEscape(value string) string {
+	return strings.ReplaceAll(value, ",", ",,")
+}
+
+func smbiosArgument(structure SMBIOSConfig) string {
+	parts := []string{fmt.Sprintf("type=%d", structure.Type)}
+
+	keys := make([]string, 0, len(structure.Fields))
+	for key := range structure.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, qemuEscape(structure.Fields[key])))
+	}
+
+	for _, value := range structure.Values {
+		parts = append(parts, fmt.Sprintf("value=%s", qemuEscape(value)))
+	}
+
+	return strings.Join(parts, ",")
+}
+
 func processArgs(args [][]string, ctx *interpolate.Context) ([][]string, error) {
 	var err error
 
@@ -3,11 +3,13 @@ package qemu
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -85,6 +87,24 @@ func Test_UserOverrides(t *testing.T) {
 			},
 			"Net device gets added",
 		},
+		{
+			&Config{
+				VMName: "myvm",
+				NetworkInterfaces: []NetworkInterfaceConfig{
+					{Backend: "user", Model: "virtio-net", Communicator: true},
+					{Backend: "user", Model: "virtio-net"},
+				},
+				QemuArgs: [][]string{{"-device", "virtio-net,netdev=user.0,mac=52:54:00:12:34:56"}},
+			},
+			[]string{
+				"-display", "gtk",
+				"-netdev", "user,id=user.1",
+				"-drive", "file=/path/to/test.iso,media=cdrom",
+				"-device", "virtio-net,netdev=user.0,mac=52:54:00:12:34:56",
+				"-device", "virtio-net,netdev=user.1",
+			},
+			"Missing network interfaces devices get added",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -115,6 +135,27 @@ func Test_UserOverrides(t *testing.T) {
 
 }
 
+func Test_UserObjectOverride(t *testing.T) {
+	c := testPrepareConfig(t, map[string]interface{}{
+		"machine_type": "q35",
+		"qemuargs":     [][]string{{"-object", "iothread,id=io0"}},
+	})
+	state := runTestState(t, c)
+
+	step := &stepRun{
+		atLeastVersion2: true,
+		ui:              packersdk.TestUi(t),
+	}
+	args, err := step.getCommandArgs(c, state)
+	if err != nil {
+		t.Fatalf("should not have an error getting args. Error: %s", err)
+	}
+
+	assert.Subset(t, args, []string{"-object", "iothread,id=io0"})
+	assert.NotContains(t, args, "rng-random,id=rng,filename=/dev/urandom")
+	assert.NotContains(t, args, "virtio-rng-pci,rng=rng")
+}
+
 func Test_DriveAndDeviceArgs(t *testing.T) {
 	type testCase struct {
 		Config     *Config
@@ -399,6 +440,31 @@ func Test_DriveAndDeviceArgs(t *testing.T) {
 			},
 			"virtio interface with disk image",
 		},
+		{
+			&Config{
+				DiskImage:     true,
+				OutputDir:     "path_to_output",
+				DiskInterface: "virtio",
+				DiskCache:     "writeback",
+				Format:        "qcow2",
+			},
+			map[string]interface{}{
+				"cloud_init_seed_path": "fake_seed.iso",
+				"qemu_disk_paths":      []string{"path_to_output"},
+			},
+			&stepRun{
+				DiskImage:       true,
+				atLeastVersion2: true,
+				ui:              packersdk.TestUi(t),
+			},
+			[]string{
+				"-display", "gtk",
+				"-boot", "c",
+				"-drive", "file=path_to_output,if=virtio,cache=writeback,discard=,format=qcow2,detect-zeroes=",
+				"-drive", "file=fake_seed.iso,media=cdrom",
+			},
+			"cloud-init seed is attached as a cdrom",
+		},
 	}
 	for _, tc := range testcases {
 		state := runTestState(t, &Config{})
@@ -466,6 +532,8 @@ func Test_OptionalConfigOptionsGetSet(t *testing.T) {
 // Tests for presence of Packer-generated arguments. Doesn't test that
 // arguments which shouldn't be there are absent.
 func Test_Defaults(t *testing.T) {
+	testPath(t, "socat")
+
 	type testCase struct {
 		Config     *Config
 		ExtraState map[string]interface{}
@@ -588,6 +656,170 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-netdev", "user,id=user.0,hostfwd=tcp::1111-:4567"},
 			"Host forwarding when a communicator is configured",
 		},
+		{
+			&Config{
+				NetIPv6: true,
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+						SSH: communicator.SSH{
+							SSHPort: 4567,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"commHostPort": 1111,
+			},
+			&stepRun{ui: packersdk.TestUi(t), ipv6HostForward: true},
+			[]string{"-netdev", "user,id=user.0,ipv6=on,hostfwd=tcp::1111-:4567,hostfwd=tcp:[::1]:1111-:4567"},
+			"IPv6 host forwarding when IPv6 is enabled",
+		},
+		{
+			&Config{
+				NetIPv6: true,
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+						SSH: communicator.SSH{
+							SSHPort: 4567,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"commHostPort": 1111,
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0,ipv6=on,hostfwd=tcp::1111-:4567"},
+			"No IPv6 host forwarding before QEMU 6.1",
+		},
+		{
+			&Config{
+				NetworkInterfaces: []NetworkInterfaceConfig{
+					{Backend: "bridge", Bridge: "virbr0", Model: "e1000"},
+					{Backend: "user", Model: "virtio-net", Communicator: true},
+				},
+				CommConfig: CommConfig{
+					Comm: communicator.Config{
+						Type: "ssh",
+						SSH: communicator.SSH{
+							SSHPort: 4567,
+						},
+					},
+				},
+			},
+			map[string]interface{}{
+				"commHostPort": 1111,
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-netdev", "bridge,id=user.0,br=virbr0",
+				"-netdev", "user,id=user.1,hostfwd=tcp::1111-:4567",
+			},
+			"Host forwarding on the communicator network interface",
+		},
+		{
+			&Config{
+				NetworkInterfaces: []NetworkInterfaceConfig{
+					{Backend: "tap", Ifname: "tap0", Model: "virtio-net", MACAddress: "52:54:00:12:34:56"},
+					{Backend: "socket", SocketMode: "listen", SocketAddress: ":1234", Model: "e1000"},
+					{Backend: "vde", VDESocket: "/run/vde.ctl", Model: "e1000"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-netdev", "tap,id=user.0,ifname=tap0,script=no,downscript=no",
+				"-netdev", "socket,id=user.1,listen=:1234",
+				"-netdev", "vde,id=user.2,sock=/run/vde.ctl",
+				"-device", "virtio-net,netdev=user.0,mac=52:54:00:12:34:56",
+				"-device", "e1000,netdev=user.1",
+				"-device", "e1000,netdev=user.2",
+			},
+			"network interfaces backends",
+		},
+		{
+			&Config{
+				NetworkInterfaces: []NetworkInterfaceConfig{
+					{Backend: "passt", Model: "virtio-net", Communicator: true},
+				},
+			},
+			map[string]interface{}{
+				"passt_sockets": map[string]string{"user.0": "/tmp/packer-passt/user.0.socket"},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "stream,id=user.0,server=off,addr.type=unix,addr.path=/tmp/packer-passt/user.0.socket"},
+			"passt network interface should connect to the passt socket",
+		},
+		{
+			&Config{
+				NetDevice:  "virtio-net",
+				MACAddress: "52:54:00:aa:bb:cc",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-net,netdev=user.0,mac=52:54:00:aa:bb:cc"},
+			"mac address should be set on the network device",
+		},
+		{
+			&Config{
+				NetworkInterfaces: []NetworkInterfaceConfig{
+					{Backend: "tap", Ifname: "tap0", Model: "virtio-net", Communicator: true},
+					{Backend: "user", Model: "virtio-net"},
+				},
+				PortForwards: []PortForwardConfig{
+					{Name: "web", GuestPort: 80, Protocol: "tcp"},
+					{Name: "dns", GuestPort: 53, Protocol: "udp"},
+				},
+			},
+			map[string]interface{}{
+				"port_forwards": map[string]int{"web": 8080, "dns": 5353},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.1,hostfwd=tcp::8080-:80,hostfwd=udp::5353-:53"},
+			"port forwards should be set on the first user network interface",
+		},
+		{
+			&Config{
+				NetworkMode:      "restricted",
+				NetworkAllowlist: []string{"mirror.example.com:80"},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0,restrict=on,hostfwd=tcp::5000-:0" +
+				",guestfwd=tcp:10.0.2.254:1234-cmd:socat - TCP:127.0.0.1:1234" +
+				",guestfwd=tcp:10.0.2.254:80-cmd:socat - TCP:mirror.example.com:80"},
+			"restricted network should only reach the HTTP server and the allowlist",
+		},
+		{
+			&Config{
+				GuestForwards: []GuestForwardConfig{
+					{Name: "proxy", GuestAddress: "10.0.2.100:3128", Target: "127.0.0.1:3128"},
+					{Name: "cache", GuestAddress: "10.0.2.101:80", Command: "socat - TCP:cache,port=80"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-netdev", "user,id=user.0,hostfwd=tcp::5000-:0" +
+				",guestfwd=tcp:10.0.2.100:3128-cmd:socat - TCP:127.0.0.1:3128" +
+				",guestfwd=tcp:10.0.2.101:80-cmd:socat - TCP:cache,,port=80"},
+			"guest forwards should be set on the user network interface",
+		},
+		{
+			&Config{
+				GuestForwards: []GuestForwardConfig{
+					{Name: "proxy", GuestAddress: "10.0.2.100:3128", Target: "127.0.0.1:3128"},
+				},
+				FwCfg: []FwCfgConfig{
+					{Name: "opt/com.example/proxy", String: "http://{{ .GuestForwards.proxy }}"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-fw_cfg", "name=opt/com.example/proxy,string=http://10.0.2.100:3128"},
+			"guest forwards should be available in templates",
+		},
 		{
 			&Config{
 				VNCBindAddress: "1.1.1.1",
@@ -613,13 +845,212 @@ func Test_Defaults(t *testing.T) {
 		},
 		{
 			&Config{
-				MemorySize: 2345,
+				VNCSocket:      true,
+				VNCUsePassword: true,
+			},
+			map[string]interface{}{
+				"vnc_port":        0,
+				"vnc_socket_path": "output/packer-vm.vnc",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-vnc", "unix:output/packer-vm.vnc,password"},
+			"VNC should listen on the socket",
+		},
+		{
+			&Config{
+				DisplayProtocol:  "spice",
+				SPICEBindAddress: "127.0.0.1",
+			},
+			map[string]interface{}{
+				"spice_port": 5931,
+			},
+			&stepRun{ui: packersdk.TestUi(t), spiceTicketingValue: true},
+			[]string{"-spice", "port=5931,addr=127.0.0.1,disable-ticketing=on"},
+			"SPICE should be enabled without a password",
+		},
+		{
+			&Config{
+				DisplayProtocol:  "spice",
+				SPICEBindAddress: "127.0.0.1",
+			},
+			map[string]interface{}{
+				"spice_port": 5931,
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-spice", "port=5931,addr=127.0.0.1,disable-ticketing"},
+			"SPICE should be enabled without a password before QEMU 5.0",
+		},
+		{
+			&Config{
+				DisplayProtocol:  "spice",
+				SPICEBindAddress: "0.0.0.0",
+			},
+			map[string]interface{}{
+				"spice_port":     5931,
+				"spice_password": "secret",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-spice", "port=5931,addr=0.0.0.0"},
+			"SPICE password should be set through QMP",
+		},
+		{
+			&Config{
+				memoryMiB: 2345,
 			},
 			map[string]interface{}{},
 			&stepRun{ui: packersdk.TestUi(t)},
 			[]string{"-m", "2345M"},
 			"Memory is set, with unit M",
 		},
+		{
+			&Config{
+				MaxMemory:    "4096M",
+				MemorySlots:  2,
+				memoryMiB:    1024,
+				maxMemoryMiB: 4096,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-m", "size=1024M,slots=2,maxmem=4096M"},
+			"Memory hotplug is set up with max_memory",
+		},
+		{
+			&Config{
+				MemoryBalloon: true,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-balloon"},
+			"the balloon device should be attached",
+		},
+		{
+			&Config{
+				USBController: "qemu-xhci",
+				InputDevices:  []string{"usb-tablet", "virtio-keyboard-pci"},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-device", "qemu-xhci,id=usb",
+				"-device", "usb-tablet",
+				"-device", "virtio-keyboard-pci",
+			},
+			"the USB controller and the input devices should be attached",
+		},
+		{
+			&Config{
+				AudioDevice: "intel-hda",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-audiodev", "none,id=audio",
+				"-device", "intel-hda",
+				"-device", "hda-duplex,audiodev=audio",
+			},
+			"the HDA sound card should get a codec",
+		},
+		{
+			&Config{
+				AudioDevice: "ac97",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-audiodev", "none,id=audio",
+				"-device", "ac97,audiodev=audio",
+			},
+			"the sound card should use the audio backend",
+		},
+		{
+			&Config{
+				VGA:         "none",
+				VideoDevice: "virtio-gpu-pci",
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-vga", "none",
+				"-device", "virtio-gpu-pci",
+			},
+			"the video device should replace the VGA adapter",
+		},
+		{
+			&Config{
+				RNG: config.TriTrue,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-object", "rng-random,id=rng,filename=/dev/urandom",
+				"-device", "virtio-rng-pci,rng=rng",
+			},
+			"the RNG device should be attached",
+		},
+		{
+			&Config{
+				RNG:         config.TriTrue,
+				RNGMaxBytes: 1024,
+				RNGPeriod:   2 * time.Second,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-rng-pci,rng=rng,max-bytes=1024,period=2000"},
+			"the RNG device should be rate limited",
+		},
+		{
+			&Config{
+				MachineType:     "q35",
+				Accelerator:     "kvm",
+				SecureBoot:      true,
+				EFIFirmwareCode: "/usr/share/OVMF/OVMF_CODE_4M.secboot.fd",
+			},
+			map[string]interface{}{
+				"efi_vars_path": "output/efivars.fd",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-machine", "type=q35,accel=kvm,smm=on",
+				"-global", "driver=cfi.pflash01,property=secure,value=on",
+				"-drive", "if=pflash,format=raw,unit=0,file=/usr/share/OVMF/OVMF_CODE_4M.secboot.fd,readonly=on",
+				"-drive", "if=pflash,format=raw,unit=1,file=output/efivars.fd",
+			},
+			"Secure Boot should use SMM and the UEFI firmware in flash memory",
+		},
+		{
+			&Config{
+				memoryMiB:     1024,
+				MemoryBackend: "memfd",
+				MemoryShare:   true,
+				SharedFolders: []SharedFolderConfig{
+					{Name: "inputs", HostPath: "/srv/inputs", Tag: "inputs", Type: "virtiofs"},
+				},
+			},
+			map[string]interface{}{
+				"virtiofs_sockets": map[string]string{"inputs": "/tmp/packer-virtiofsd/inputs.socket"},
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-object", "memory-backend-memfd,id=mem,size=1024M,share=on",
+				"-chardev", "socket,id=fs.inputs,path=/tmp/packer-virtiofsd/inputs.socket",
+				"-device", "vhost-user-fs-pci,chardev=fs.inputs,tag=inputs",
+			},
+			"virtiofs shared folders should connect to virtiofsd",
+		},
+		{
+			&Config{
+				SharedFolders: []SharedFolderConfig{
+					{Name: "inputs", HostPath: "/srv/inputs", Tag: "data", Type: "9p", ReadOnly: true, SecurityModel: "mapped-xattr"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-fsdev", "local,id=fs.inputs,path=/srv/inputs,security_model=mapped-xattr,readonly=on",
+				"-device", "virtio-9p-pci,fsdev=fs.inputs,mount_tag=data",
+			},
+			"9p shared folders should be attached",
+		},
 		{
 			&Config{
 				CpuCount: 2,
@@ -629,6 +1060,67 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-smp", "cpus=2,sockets=2"},
 			"both cpus and sockets are set to config's CpuCount",
 		},
+		{
+			&Config{
+				CpuCount: 8,
+				Sockets:  1,
+				Cores:    4,
+				Threads:  2,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-smp", "cpus=8,sockets=1,cores=4,threads=2"},
+			"the CPU topology should be set",
+		},
+		{
+			&Config{
+				CPUModel:    "host",
+				CPUFeatures: []string{"-hypervisor", "kvm=off"},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-cpu", "host,-hypervisor,kvm=off"},
+			"the CPU model and features should be set",
+		},
+		{
+			&Config{
+				MachineType:     "q35",
+				Accelerator:     "kvm",
+				memoryMiB:       2048,
+				MemoryBackend:   "memfd",
+				MemoryHugepages: true,
+				MemoryShare:     true,
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-machine", "type=q35,accel=kvm,memory-backend=mem",
+				"-object", "memory-backend-memfd,id=mem,size=2048M,hugetlb=on,share=on",
+			},
+			"the memory backend should hold the memory of the VM",
+		},
+		{
+			&Config{
+				CpuCount:          4,
+				memoryMiB:         2048,
+				MemoryBackend:     "file",
+				MemoryBackendPath: "/dev/hugepages",
+				MemoryPrealloc:    true,
+				NUMANodes: []NUMANodeConfig{
+					{CPUs: "0-1", HostNodes: "0", memoryMiB: 1024},
+					{CPUs: "2,3", memoryMiB: 1024},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{
+				"-object", "memory-backend-file,id=mem.0,size=1024M,mem-path=/dev/hugepages,prealloc=on,host-nodes=0,policy=bind",
+				"-object", "memory-backend-file,id=mem.1,size=1024M,mem-path=/dev/hugepages,prealloc=on",
+				"-numa", "node,nodeid=0,cpus=0-1,memdev=mem.0",
+				"-numa", "node,nodeid=1,cpus=2,cpus=3,memdev=mem.1",
+			},
+			"the NUMA nodes should have their own memory backend",
+		},
 		{
 			&Config{
 				CpuCount: 2,
@@ -675,6 +1167,112 @@ func Test_Defaults(t *testing.T) {
 			[]string{"-display", "gtk"},
 			"Display option should default to gtk",
 		},
+		{
+			&Config{},
+			map[string]interface{}{
+				"kernel_path": "/path/to/vmlinuz",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-kernel", "/path/to/vmlinuz"},
+			"kernel should be set under kernel flag, when it exists",
+		},
+		{
+			&Config{},
+			map[string]interface{}{
+				"kernel_path": "/path/to/vmlinuz",
+				"initrd_path": "/path/to/initrd.gz",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-initrd", "/path/to/initrd.gz"},
+			"initrd should be set under initrd flag, when it exists",
+		},
+		{
+			&Config{
+				VMName:        "myvm",
+				KernelCmdline: "url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg hostname={{ .Name }}",
+			},
+			map[string]interface{}{
+				"kernel_path": "/path/to/vmlinuz",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-append", "url=http://127.0.0.1:1234/preseed.cfg hostname=myvm"},
+			"kernel command line should be interpolated",
+		},
+		{
+			&Config{
+				KernelCmdline: "url=http://{{ .HTTPHost }}:{{ .HTTPPort }}/preseed.cfg",
+			},
+			map[string]interface{}{
+				"kernel_path": "/path/to/vmlinuz",
+				"http_ip":     "fd00::1",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-append", "url=http://[fd00::1]:1234/preseed.cfg"},
+			"HTTPHost should enclose IPv6 addresses in brackets",
+		},
+		{
+			&Config{
+				FwCfg: []FwCfgConfig{
+					{Name: "opt/com.example/url", String: "http://{{ .HTTPIP }}:{{ .HTTPPort }}/a,b"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-fw_cfg", "name=opt/com.example/url,string=http://127.0.0.1:1234/a,,b"},
+			"fw_cfg string should be interpolated and escaped",
+		},
+		{
+			&Config{
+				FwCfg: []FwCfgConfig{
+					{Name: "opt/com.example/url", String: "foo"},
+					{Name: "opt/com.example/config", File: "/path/to/config.json"},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-fw_cfg", "name=opt/com.example/config,file=/path/to/config.json"},
+			"fw_cfg file should be set",
+		},
+		{
+			&Config{
+				SMBIOS: []SMBIOSConfig{
+					{Type: 1, Fields: map[string]string{"serial": "1234", "manufacturer": "Example, Inc."}},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-smbios", "type=1,manufacturer=Example,, Inc.,serial=1234"},
+			"smbios fields should be sorted and escaped",
+		},
+		{
+			&Config{
+				SMBIOS: []SMBIOSConfig{
+					{Type: 11, Values: []string{"io.systemd.credential:foo=bar", "io.systemd.credential:baz=qux"}},
+				},
+			},
+			map[string]interface{}{},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-smbios", "type=11,value=io.systemd.credential:foo=bar,value=io.systemd.credential:baz=qux"},
+			"smbios OEM strings should be set as values",
+		},
+		{
+			&Config{QemuBinary: "qemu-system-x86_64"},
+			map[string]interface{}{
+				"ignition_config_path": "/tmp/config.ign",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-fw_cfg", "name=opt/com.coreos/config,file=/tmp/config.ign"},
+			"ignition config should be passed through fw_cfg on x86_64",
+		},
+		{
+			&Config{QemuBinary: "qemu-system-s390x"},
+			map[string]interface{}{
+				"ignition_config_path": "/tmp/config.ign",
+			},
+			&stepRun{ui: packersdk.TestUi(t)},
+			[]string{"-device", "virtio-blk,serial=ignition,drive=ignition"},
+			"ignition config should be attached as a drive on s390x",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -708,3 +1306,49 @@ func matchArgument(actual []string, expected []string) bool {
 	}
 	return false
 }
+
+func TestRelayCommand(t *testing.T) {
+	testPath(t, "nc")
+	if relay, err := relayCommand("[::1]:80"); err != nil || relay != "nc ::1 80" {
+		t.Fatalf("bad nc relay: %q, %v", relay, err)
+	}
+
+	testPath(t, "nc", "socat")
+	if relay, err := relayCommand("[::1]:80"); err != nil || relay != "socat - TCP:[::1]:80" {
+		t.Fatalf("bad socat relay: %q, %v", relay, err)
+	}
+
+	testPath(t)
+	if _, err := relayCommand("127.0.0.1:80"); err == nil {
+		t.Fatal("should have error without socat and nc")
+	}
+}
+
+func TestGuestForwardConfig_guestfwdOption(t *testing.T) {
+	testPath(t, "nc")
+
+	gf := GuestForwardConfig{Name: "proxy", GuestAddress: "10.0.2.100:3128", Target: "127.0.0.1:3128"}
+	option, err := gf.guestfwdOption()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if option != "guestfwd=tcp:10.0.2.100:3128-cmd:nc 127.0.0.1 3128" {
+		t.Fatalf("bad target guestfwd option: %s", option)
+	}
+
+	gf = GuestForwardConfig{Name: "cache", GuestAddress: "10.0.2.101:80", Command: "socat - TCP:cache,port=80"}
+	option, err = gf.guestfwdOption()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if option != "guestfwd=tcp:10.0.2.101:80-cmd:socat - TCP:cache,,port=80" {
+		t.Fatalf("bad command guestfwd option: %s", option)
+	}
+
+	// A target can't be relayed without socat or nc
+	testPath(t)
+	gf = GuestForwardConfig{Name: "proxy", GuestAddress: "10.0.2.100:3128", Target: "127.0.0.1:3128"}
+	if _, err := gf.guestfwdOption(); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,165 @@
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// The network configuration passt gives the guest through DHCP, matching the
+// QEMU user mode networking, so that the guest address is known up front.
+// Connections to the gateway are mapped to the loopback of the host.
+const (
+	passtGuestIP   = userNetworkGuestIP
+	passtGatewayIP = "10.0.2.2"
+)
+
+// This step starts a passt process for each network interface using the
+// passt backend.
+//
+// Uses:
+//   commHostPort int
+//   driver       Driver
+//   ui           packersdk.Ui
+//
+// Produces:
+//   passt_sockets map[string]string - The passt socket path by netdev id.
+type stepStartPasst struct {
+	CommunicatorType  string
+	CommunicatorPort  int
+	CommNetdevID      string
+	NetworkInterfaces []NetworkInterfaceConfig
+
+	dir       string
+	passtPath string
+	sidecars  []*sidecar
+}
+
+func (s *stepStartPasst) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	sockets := make(map[string]string)
+	for i, iface := range s.NetworkInterfaces {
+		if iface.Backend != "passt" {
+			continue
+		}
+		id := netdevID(i)
+
+		if s.dir == "" {
+			driver := state.Get("driver").(Driver)
+			if err := checkStreamNetdev(driver); err != nil {
+				err := fmt.Errorf("The passt network backend requires -netdev stream: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			var err error
+			s.passtPath, err = exec.LookPath("passt")
+			if err != nil {
+				err := fmt.Errorf("passt is required by the passt network backend: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			log.Printf("Using passt: %s", s.passtPath)
+
+			// Unix socket paths are limited to about 100 characters, which
+			// the output directory may exceed.
+			s.dir, err = os.MkdirTemp("", "packer-passt")
+			if err != nil {
+				err := fmt.Errorf("Error creating the passt socket directory: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		socketPath := filepath.Join(s.dir, id+".socket")
+		args := []string{
+			"--foreground",
+			"--socket", socketPath,
+			"--address", passtGuestIP,
+			"--netmask", "24",
+			"--gateway", passtGatewayIP,
+		}
+		if id == s.CommNetdevID && s.CommunicatorType != "none" {
+			commHostPort := state.Get("commHostPort").(int)
+			args = append(args, "--tcp-ports", fmt.Sprintf("%d:%d", commHostPort, s.CommunicatorPort))
+		}
+
+		ui.Say(fmt.Sprintf("Starting passt for the %s network interface...", id))
+		passt := &sidecar{
+			Name: "passt " + id,
+			Path: s.passtPath,
+			Args: args,
+		}
+		if err := passt.Start(); err != nil {
+			err := fmt.Errorf("Error starting passt: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.sidecars = append(s.sidecars, passt)
+
+		if err := passt.WaitForFile(socketPath, 10*time.Second); err != nil {
+			err := fmt.Errorf("Error starting passt: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		sockets[id] = socketPath
+	}
+
+	if len(sockets) > 0 {
+		state.Put("passt_sockets", sockets)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepStartPasst) Cleanup(state multistep.StateBag) {
+	for _, passt := range s.sidecars {
+		if err := passt.Stop(); err != nil {
+			log.Printf("Error stopping %s: %s", passt.Name, err)
+		}
+	}
+
+	if s.dir != "" {
+		if err := os.RemoveAll(s.dir); err != nil {
+			log.Printf("Error removing the passt socket directory %s: %s", s.dir, err)
+		}
+	}
+}
+
+// checkStreamNetdev returns an error unless the QEMU version supports
+// -netdev stream, added in 7.2.
+func checkStreamNetdev(driver Driver) error {
+	rawVersion, err := driver.Version()
+	if err != nil {
+		return fmt.Errorf("Error determining qemu version: %s", err)
+	}
+	qemuVersion, err := version.NewVersion(rawVersion)
+	if err != nil {
+		return fmt.Errorf("Error parsing qemu version: %s", err)
+	}
+	if qemuVersion.LessThan(version.Must(version.NewVersion("7.2"))) {
+		return fmt.Errorf("QEMU 7.2 or later is required, found %s", rawVersion)
+	}
+	return nil
+}
+
+// passtNetdevArgument returns the -netdev argument connecting to the passt
+// socket.
+func passtNetdevArgument(id string, socketPath string) string {
+	return fmt.Sprintf("stream,id=%s,server=off,addr.type=unix,addr.path=%s", id, qemuEscape(socketPath))
+}
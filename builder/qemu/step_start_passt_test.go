@@ -0,0 +1,55 @@
+package qemu
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepStartPasst_impl(t *testing.T) {
+	var _ multistep.Step = new(stepStartPasst)
+}
+
+func TestStepStartPasst_noPasstInterface(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+
+	step := &stepStartPasst{
+		CommunicatorType:  "ssh",
+		NetworkInterfaces: []NetworkInterfaceConfig{{Backend: "user"}},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("passt_sockets"); ok {
+		t.Fatal("passt_sockets should not be set")
+	}
+	step.Cleanup(state)
+}
+
+func TestStepStartPasst_oldQemu(t *testing.T) {
+	state := testState(t)
+	state.Get("driver").(*DriverMock).VersionResult = "7.1.0"
+
+	step := &stepStartPasst{
+		CommunicatorType:  "ssh",
+		NetworkInterfaces: []NetworkInterfaceConfig{{Backend: "passt"}},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	step.Cleanup(state)
+}
+
+func TestPasstNetdevArgument(t *testing.T) {
+	got := passtNetdevArgument("user.1", "/tmp/a,b/user.1.socket")
+	want := "stream,id=user.1,server=off,addr.type=unix,addr.path=/tmp/a,,b/user.1.socket"
+	if got != want {
+		t.Fatalf("bad netdev argument: got %q, want %q", got, want)
+	}
+}
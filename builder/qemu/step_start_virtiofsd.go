@@ -0,0 +1,153 @@
+package qemu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// virtiofsdPaths are the usual locations of virtiofsd, which distributions
+// install outside of the PATH. The legacy virtiofsd of QEMU, installed in
+// /usr/lib/qemu, is left out.
+var virtiofsdPaths = []string{
+	"/usr/libexec/virtiofsd",
+	"/usr/lib/virtiofsd",
+}
+
+// This step starts a virtiofsd process for each shared folder using the
+// virtiofs type.
+//
+// Uses:
+//   ui packersdk.Ui
+//
+// Produces:
+//   virtiofs_sockets map[string]string - The virtiofsd socket path by shared
+//     folder name.
+type stepStartVirtiofsd struct {
+	SharedFolders []SharedFolderConfig
+
+	dir           string
+	virtiofsdPath string
+	sidecars      []*sidecar
+}
+
+func (s *stepStartVirtiofsd) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packersdk.Ui)
+
+	sockets := make(map[string]string)
+	for _, sf := range s.SharedFolders {
+		if sf.Type != "virtiofs" {
+			continue
+		}
+
+		if s.dir == "" {
+			var err error
+			s.virtiofsdPath, err = lookPathVirtiofsd()
+			if err != nil {
+				err := fmt.Errorf("virtiofsd is required by the virtiofs shared folders: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+			log.Printf("Using virtiofsd: %s", s.virtiofsdPath)
+
+			// Unix socket paths are limited to about 100 characters, which
+			// the output directory may exceed.
+			s.dir, err = os.MkdirTemp("", "packer-virtiofsd")
+			if err != nil {
+				err := fmt.Errorf("Error creating the virtiofsd socket directory: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+
+		socketPath := filepath.Join(s.dir, sf.Name+".socket")
+
+		ui.Say(fmt.Sprintf("Starting virtiofsd for the %s shared folder...", sf.Name))
+		virtiofsd := &sidecar{
+			Name: "virtiofsd " + sf.Name,
+			Path: s.virtiofsdPath,
+			Args: sf.virtiofsdArgs(socketPath, os.Geteuid() != 0),
+		}
+		if err := virtiofsd.Start(); err != nil {
+			err := fmt.Errorf("Error starting virtiofsd: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.sidecars = append(s.sidecars, virtiofsd)
+
+		if err := virtiofsd.WaitForFile(socketPath, 10*time.Second); err != nil {
+			err := fmt.Errorf("Error starting virtiofsd: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		sockets[sf.Name] = socketPath
+	}
+
+	if len(sockets) > 0 {
+		state.Put("virtiofs_sockets", sockets)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepStartVirtiofsd) Cleanup(state multistep.StateBag) {
+	for _, virtiofsd := range s.sidecars {
+		if err := virtiofsd.Stop(); err != nil {
+			log.Printf("Error stopping %s: %s", virtiofsd.Name, err)
+		}
+	}
+
+	if s.dir != "" {
+		if err := os.RemoveAll(s.dir); err != nil {
+			log.Printf("Error removing the virtiofsd socket directory %s: %s", s.dir, err)
+		}
+	}
+}
+
+// lookPathVirtiofsd looks the Rust virtiofsd up in the PATH, then in
+// virtiofsdPaths. The legacy virtiofsd of QEMU, which was installed in the
+// same places before QEMU 8.0, takes other options and is skipped.
+func lookPathVirtiofsd() (string, error) {
+	var paths []string
+	path, err := exec.LookPath("virtiofsd")
+	if err == nil {
+		paths = append(paths, path)
+	}
+	for _, path := range virtiofsdPaths {
+		if info, statErr := os.Stat(path); statErr == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", err
+	}
+
+	for _, path := range paths {
+		if rustVirtiofsd(path) {
+			return path, nil
+		}
+		log.Printf("Skipping %s, which is the legacy virtiofsd of QEMU", path)
+	}
+	return "", fmt.Errorf("%s is the legacy virtiofsd of QEMU, the Rust virtiofsd is required", strings.Join(paths, ", "))
+}
+
+// rustVirtiofsd returns whether the virtiofsd at path is the Rust one, from
+// its help listing the --shared-dir option.
+func rustVirtiofsd(path string) bool {
+	out, _ := exec.Command(path, "--help").CombinedOutput()
+	return bytes.Contains(out, []byte("--shared-dir"))
+}
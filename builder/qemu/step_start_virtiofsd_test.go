@@ -0,0 +1,78 @@
+package qemu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepStartVirtiofsd_impl(t *testing.T) {
+	var _ multistep.Step = new(stepStartVirtiofsd)
+}
+
+func TestStepStartVirtiofsd_noVirtiofsFolder(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+
+	step := &stepStartVirtiofsd{
+		SharedFolders: []SharedFolderConfig{{Name: "inputs", HostPath: "/srv/inputs", Type: "9p"}},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("virtiofs_sockets"); ok {
+		t.Fatal("virtiofs_sockets should not be set")
+	}
+	step.Cleanup(state)
+}
+
+func TestSharedFolderConfig_virtiofsdArgs(t *testing.T) {
+	sf := SharedFolderConfig{Name: "inputs", HostPath: "/srv/inputs", ReadOnly: true}
+	got := sf.virtiofsdArgs("/tmp/inputs.socket", true)
+	want := []string{
+		"--socket-path", "/tmp/inputs.socket",
+		"--shared-dir", "/srv/inputs",
+		"--cache", "auto",
+		"--readonly",
+		"--sandbox", "none",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad virtiofsd arguments: got %#v, want %#v", got, want)
+	}
+}
+
+func TestLookPathVirtiofsd(t *testing.T) {
+	dir := t.TempDir()
+	virtiofsd := filepath.Join(dir, "virtiofsd")
+	t.Setenv("PATH", dir)
+	defer func(paths []string) { virtiofsdPaths = paths }(virtiofsdPaths)
+	virtiofsdPaths = nil
+
+	if _, err := lookPathVirtiofsd(); err == nil {
+		t.Fatal("should error without virtiofsd")
+	}
+
+	// The legacy virtiofsd takes the shared directory as a -o option
+	if err := os.WriteFile(virtiofsd, []byte("#!/bin/sh\necho '    -o source=PATH'\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := lookPathVirtiofsd(); err == nil {
+		t.Fatal("should error with the legacy virtiofsd")
+	}
+
+	if err := os.WriteFile(virtiofsd, []byte("#!/bin/sh\necho '    --shared-dir <shared-dir>'\n"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path, err := lookPathVirtiofsd()
+	if err != nil {
+		t.Fatalf("should not error with the Rust virtiofsd: %s", err)
+	}
+	if path != virtiofsd {
+		t.Fatalf("bad path: %s", path)
+	}
+}
@@ -2,6 +2,8 @@ package qemu
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
@@ -17,3 +19,15 @@ func testState(t *testing.T) multistep.StateBag {
 	})
 	return state
 }
+
+// testPath sets the PATH to a directory holding empty executables named
+// after commands, for the steps looking them up.
+func testPath(t *testing.T, commands ...string) {
+	dir := t.TempDir()
+	for _, command := range commands {
+		if err := os.WriteFile(filepath.Join(dir, command), []byte{}, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	t.Setenv("PATH", dir)
+}
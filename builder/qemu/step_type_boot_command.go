@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
@@ -17,9 +18,11 @@ import (
 const KeyLeftShift uint32 = 0xFFE1
 
 type bootCommandTemplateData struct {
-	HTTPIP   string
-	HTTPPort int
-	Name     string
+	HTTPIP        string
+	HTTPHost      string
+	HTTPPort      int
+	Name          string
+	GuestForwards map[string]string
 }
 
 // This step "types" the boot command into the VM over VNC.
@@ -29,6 +32,7 @@ type bootCommandTemplateData struct {
 //   http_port int
 //   ui     packersdk.Ui
 //   vnc_port int
+//   vnc_socket_path string
 //
 // Produces:
 //   <nothing>
@@ -65,9 +69,13 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 	}
 
 	// Connect to VNC
-	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s:%d)", vncIP, vncPort))
+	network, address := "tcp", net.JoinHostPort(vncIP, strconv.Itoa(vncPort))
+	if socketPath, ok := state.Get("vnc_socket_path").(string); ok {
+		network, address = "unix", socketPath
+	}
+	ui.Say(fmt.Sprintf("Connecting to VM via VNC (%s)", address))
 
-	nc, err := net.Dial("tcp", fmt.Sprintf("%s:%d", vncIP, vncPort))
+	nc, err := net.Dial(network, address)
 	if err != nil {
 		err := fmt.Errorf("Error connecting to VNC: %s", err)
 		state.Put("error", err)
@@ -99,8 +107,10 @@ func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag)
 	configCtx := config.ctx
 	configCtx.Data = &bootCommandTemplateData{
 		hostIP,
+		bracketIPv6(hostIP),
 		httpPort,
 		config.VMName,
+		config.guestForwardAddresses(),
 	}
 
 	d := bootcommand.NewVNCDriver(c, config.VNCConfig.BootKeyInterval)
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
@@ -12,47 +13,98 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 
 	"github.com/digitalocean/go-qemu/qmp"
 )
 
 // This step waits for the guest address to become available in the network
-// bridge, then it sets the guestAddress state property.
+// bridge, then it sets the guestAddress state property and the GuestAddress
+// build variable.
 type stepWaitGuestAddress struct {
 	CommunicatorType string
-	NetBridge        string
+	NetworkInterface NetworkInterfaceConfig
+	NetdevID         string
+	// The sources to look the guest address up from, in order. Defaults to
+	// arp.
+	Sources          []string
+	DnsmasqLeaseFile string
+	// The fixed guest address, which isn't looked up.
+	GuestAddress string
+	// The interval between the lookups. Defaults to 10 seconds.
+	PollInterval time.Duration
 
 	timeout time.Duration
 }
 
 func (s *stepWaitGuestAddress) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packersdk.Ui)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
 
 	if s.CommunicatorType == "none" {
 		ui.Message("No communicator is configured -- skipping StepWaitGuestAddress")
 		return multistep.ActionContinue
 	}
-	if s.NetBridge == "" {
-		ui.Message("Not using a NetBridge -- skipping StepWaitGuestAddress")
+	if s.GuestAddress != "" {
+		ui.Message(fmt.Sprintf("Using the configured guest address %s", s.GuestAddress))
+		state.Put("guestAddress", s.GuestAddress)
+		generatedData.Put("GuestAddress", s.GuestAddress)
 		return multistep.ActionContinue
 	}
+	// The address of guests behind a tap interface is looked up in the
+	// tables of all the host interfaces, the tap being usually enslaved to
+	// a bridge.
+	bridgeName := ""
+	where := ""
+	switch s.NetworkInterface.Backend {
+	case "bridge":
+		bridgeName = s.NetworkInterface.Bridge
+		where = fmt.Sprintf("in the %s network bridge", bridgeName)
+	case "tap":
+		where = fmt.Sprintf("behind the %s tap interface", s.NetworkInterface.Ifname)
+	case "user", "passt":
+		// The guest gets the same address from the DHCP server of the user
+		// mode networking and of passt.
+		generatedData.Put("GuestAddress", userNetworkGuestIP)
+		fallthrough
+	default:
+		ui.Message(fmt.Sprintf("The communicator network interface uses the %s backend -- skipping StepWaitGuestAddress", s.NetworkInterface.Backend))
+		return multistep.ActionContinue
+	}
+	ui.Say(fmt.Sprintf("Waiting for the guest address to become available %s...", where))
 
-	qmpMonitor := state.Get("qmp_monitor").(*qmp.SocketMonitor)
-	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	interval := s.PollInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	qmpMonitor, _ := state.Get("qmp_monitor").(*qmp.SocketMonitor)
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	ui.Say(fmt.Sprintf("Waiting for the guest address to become available in the %s network bridge...", s.NetBridge))
+	start := time.Now()
 	for {
-		guestAddress := getGuestAddress(qmpMonitor, s.NetBridge, "user.0")
-		if guestAddress != "" {
-			log.Printf("Found guest address %s", guestAddress)
+		guestAddress, err := getGuestAddress(qmpMonitor, s.Sources, bridgeName, s.NetdevID, s.NetworkInterface.MACAddress, s.DnsmasqLeaseFile)
+		if err == nil {
+			ui.Message(fmt.Sprintf("Found the guest address %s", guestAddress))
 			state.Put("guestAddress", guestAddress)
+			generatedData.Put("GuestAddress", guestAddress)
 			return multistep.ActionContinue
 		}
+		log.Printf("Guest address not found yet: %s", err)
+
 		select {
-		case <-time.After(10 * time.Second):
-			continue
-		case <-ctx.Done():
+		case <-time.After(interval):
+			ui.Message(fmt.Sprintf("Still waiting for the guest address (%s elapsed)...", time.Since(start).Round(time.Second)))
+		case <-timeoutCtx.Done():
+			if ctx.Err() != nil {
+				// The build was cancelled
+				return multistep.ActionHalt
+			}
+			err := fmt.Errorf("Timeout waiting for the guest address %s after %s: %s. %s",
+				where, s.timeout, err, describeARPTable(bridgeName))
+			state.Put("error", err)
+			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 	}
@@ -61,22 +113,98 @@ func (s *stepWaitGuestAddress) Run(ctx context.Context, state multistep.StateBag
 func (s *stepWaitGuestAddress) Cleanup(state multistep.StateBag) {
 }
 
-func getGuestAddress(qmpMonitor *qmp.SocketMonitor, bridgeName string, deviceName string) string {
+// getGuestAddress looks up the guest address from the MAC address of the
+// network device, which is retrieved through QMP when not configured. The
+// sources are tried in order, and the error lists why each of them failed.
+func getGuestAddress(qmpMonitor *qmp.SocketMonitor, sources []string, bridgeName string, deviceName string, macAddress string, dnsmasqLeaseFile string) (string, error) {
+	if macAddress == "" {
+		var err error
+		macAddress, err = getDeviceMACAddress(qmpMonitor, deviceName)
+		if err != nil {
+			return "", err
+		}
+	}
+	// /proc/net/arp and QMP use lowercase addresses
+	macAddress = strings.ToLower(macAddress)
+
+	if len(sources) == 0 {
+		sources = []string{"arp"}
+	}
+	var errs []string
+	for _, source := range sources {
+		ipAddress, err := lookupGuestAddress(source, bridgeName, macAddress, dnsmasqLeaseFile)
+		if err == nil {
+			log.Printf("Found the address of %s from %s", macAddress, source)
+			return ipAddress, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", source, err))
+	}
+	return "", fmt.Errorf("no address found for the MAC address %s (%s)", macAddress, strings.Join(errs, "; "))
+}
+
+func getDeviceMACAddress(qmpMonitor *qmp.SocketMonitor, deviceName string) (string, error) {
+	if qmpMonitor == nil {
+		return "", fmt.Errorf("QEMU QMP is not enabled, cannot retrieve the MAC address of the network device %s", deviceName)
+	}
+
 	devices, err := getNetDevices(qmpMonitor)
 	if err != nil {
-		log.Printf("Could not retrieve QEMU QMP network device list: %v", err)
-		return ""
+		return "", fmt.Errorf("could not retrieve QEMU QMP network device list: %w", err)
 	}
 
 	for _, device := range devices {
 		if device.Name == deviceName {
-			ipAddress, _ := getDeviceIPAddress(bridgeName, device.MacAddress)
-			return ipAddress
+			return device.MacAddress, nil
+		}
+	}
+
+	return "", fmt.Errorf("QEMU QMP network device %s was not found", deviceName)
+}
+
+// describeARPTable describes the complete entries of the ARP table of device,
+// or of all the devices when empty, to help finding why the guest address
+// wasn't found.
+func describeARPTable(device string) string {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return fmt.Sprintf("The ARP table can't be read: %s", err)
+	}
+	defer f.Close()
+
+	entries, err := parseARPTable(f, device)
+	if err != nil {
+		return fmt.Sprintf("The ARP table can't be read: %s", err)
+	}
+	if len(entries) == 0 {
+		if device != "" {
+			return fmt.Sprintf("The ARP table has no entry for %s", device)
 		}
+		return "The ARP table has no entry"
 	}
+	return fmt.Sprintf("The ARP table has: %s", strings.Join(entries, ", "))
+}
 
-	log.Printf("QEMU QMP network device %s was not found", deviceName)
-	return ""
+// parseARPTable returns the complete entries of /proc/net/arp for device, or
+// for all the devices when empty, formatted as "ip (mac) on device".
+func parseARPTable(r io.Reader, device string) ([]string, error) {
+	var entries []string
+	s := bufio.NewScanner(r)
+	s.Scan()
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		if device != "" && fields[5] != device {
+			continue
+		}
+		flags, err := strconv.ParseInt(fields[2], 0, 32)
+		if err != nil || flags&0x02 == 0 {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%s (%s) on %s", fields[0], fields[3], fields[5]))
+	}
+	return entries, s.Err()
 }
 
 func getDeviceIPAddress(device string, macAddress string) (string, error) {
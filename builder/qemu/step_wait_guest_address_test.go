@@ -0,0 +1,89 @@
+package qemu
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+func TestStepWaitGuestAddress_impl(t *testing.T) {
+	var _ multistep.Step = new(stepWaitGuestAddress)
+}
+
+func TestStepWaitGuestAddress_fixedAddress(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+
+	step := &stepWaitGuestAddress{
+		CommunicatorType: "ssh",
+		NetworkInterface: NetworkInterfaceConfig{Backend: "tap", Ifname: "tap0"},
+		GuestAddress:     "192.168.122.10",
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("guestAddress") != "192.168.122.10" {
+		t.Fatalf("bad guestAddress: %#v", state.Get("guestAddress"))
+	}
+	generatedData := state.Get("generated_data").(map[string]interface{})
+	if !reflect.DeepEqual(generatedData, map[string]interface{}{"GuestAddress": "192.168.122.10"}) {
+		t.Fatalf("bad generated data: %#v", generatedData)
+	}
+}
+
+func TestStepWaitGuestAddress_timeout(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+
+	step := &stepWaitGuestAddress{
+		CommunicatorType: "ssh",
+		NetworkInterface: NetworkInterfaceConfig{Backend: "bridge", Bridge: "virbr0", MACAddress: "52:54:00:12:34:56"},
+		Sources:          []string{"dnsmasq"},
+		DnsmasqLeaseFile: filepath.Join(t.TempDir(), "dnsmasq.leases"),
+		PollInterval:     10 * time.Millisecond,
+		timeout:          50 * time.Millisecond,
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	err, ok := state.Get("error").(error)
+	if !ok {
+		t.Fatal("should have error")
+	}
+	for _, s := range []string{"virbr0", "52:54:00:12:34:56", "dnsmasq:"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error should mention %q: %s", s, err)
+		}
+	}
+}
+
+func TestParseARPTable(t *testing.T) {
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.122.111  0x1         0x2         52:54:00:12:34:56     *        virbr0
+192.168.122.112  0x1         0x0         00:00:00:00:00:00     *        virbr0
+192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+`
+
+	entries, err := parseARPTable(strings.NewReader(table), "virbr0")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	expected := []string{"192.168.122.111 (52:54:00:12:34:56) on virbr0"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+
+	entries, err = parseARPTable(strings.NewReader(table), "")
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("bad entries: %#v", entries)
+	}
+}